@@ -1,30 +1,31 @@
 package fesgo
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 type Account struct {
 	UnAuthHandler func(*Context) // 未授权处理
-	Users         map[string]string
+	// Users 用户名和密码, 密码可以是明文、bcrypt 或 argon2 的哈希, 见 HashPassword
+	Users map[string]string
+	// Validator 自定义校验, 例如从数据库查询, 设置后不再使用 Users
+	Validator func(user, password string) bool
+	Realm     string // 默认 Restricted
 }
 
 func (a *Account) BasicAuth(next HandlerFunc) HandlerFunc {
 	return func(c *Context) {
 		user, password, ok := c.R.BasicAuth()
-		if !ok {
-			c.W.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-			if a.UnAuthHandler != nil {
-				a.UnAuthHandler(c)
-			} else {
-				c.SetStatusCode(http.StatusUnauthorized)
-			}
-			return
-		}
-		if p, ok := a.Users[user]; !ok || p != password {
-			c.W.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-			c.SetStatusCode(http.StatusUnauthorized)
+		if !ok || !a.validate(user, password) {
+			a.unAuth(c)
 			return
 		}
 		c.Set("user", user)
@@ -32,7 +33,118 @@ func (a *Account) BasicAuth(next HandlerFunc) HandlerFunc {
 	}
 }
 
+func (a *Account) validate(user, password string) bool {
+	if a.Validator != nil {
+		return a.Validator(user, password)
+	}
+	stored, ok := a.Users[user]
+	if !ok {
+		// 用户不存在时同样计算一次哈希, 避免通过响应时间判断用户名是否存在
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = HashPassword("fesgo-dummy-password")
+		})
+		CheckPassword(dummyHash, password)
+		return false
+	}
+	return CheckPassword(stored, password)
+}
+
+// unAuth 所有校验失败的情况都使用同样的处理
+func (a *Account) unAuth(c *Context) {
+	realm := a.Realm
+	if realm == "" {
+		realm = "Restricted"
+	}
+	c.W.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, realm))
+	if a.UnAuthHandler != nil {
+		a.UnAuthHandler(c)
+		return
+	}
+	c.SetStatusCode(http.StatusUnauthorized)
+}
+
 func BasicAuth(username string, password string) string {
 	auth := username + ":" + password
 	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
 }
+
+// argon2id 默认参数
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// dummyHash 用户不存在时用于比较的 bcrypt 哈希, 第一次使用时生成
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// HashPassword 使用 bcrypt 计算密码的哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// HashPasswordArgon2 使用 argon2id 计算密码的哈希, 格式为 $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+func HashPasswordArgon2(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword 校验密码, stored 根据前缀识别为 bcrypt、argon2 哈希或明文, 明文使用常量时间比较
+func CheckPassword(stored, password string) bool {
+	switch {
+	case strings.HasPrefix(stored, "$2a$"), strings.HasPrefix(stored, "$2b$"), strings.HasPrefix(stored, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	case strings.HasPrefix(stored, "$argon2"):
+		return checkArgon2(stored, password)
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+func checkArgon2(stored, password string) bool {
+	// "", argon2id, v=19, m=65536,t=3,p=2, salt, hash
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return false
+	}
+	var key []byte
+	switch parts[1] {
+	case "argon2id":
+		key = argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(hash)))
+	case "argon2i":
+		key = argon2.Key([]byte(password), salt, time, memory, threads, uint32(len(hash)))
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, hash) == 1
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"github.com/dalefeng/fesgo"
+)
+
+// APIKey 从请求头或查询参数中获取 API key
+type APIKey struct {
+	Header string // 默认 X-API-Key
+	Query  string // 查询参数名, 为空时不从查询参数获取
+	// Lookup 查找 key 对应的调用方, key 不存在时返回 nil
+	Lookup func(key string) (*Principal, error)
+}
+
+func (a *APIKey) Authenticate(ctx *fesgo.Context) (*Principal, error) {
+	header := a.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	key := ctx.R.Header.Get(header)
+	if key == "" && a.Query != "" {
+		key = ctx.R.URL.Query().Get(a.Query)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	principal, err := a.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if principal == nil {
+		return nil, ErrInvalidCredentials
+	}
+	// Principal 可能是调用方共享的, 复制后再设置认证方式
+	p := *principal
+	if p.Scheme == "" {
+		p.Scheme = "apikey"
+	}
+	return &p, nil
+}
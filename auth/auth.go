@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+	"github.com/dalefeng/fesgo"
+	"net/http"
+)
+
+var (
+	// ErrNoCredentials 请求中没有当前方式的凭证, 继续尝试下一个认证方式
+	ErrNoCredentials      = errors.New("auth: no credentials")
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+)
+
+// PrincipalKey 认证结果在 Context 中的 key
+const PrincipalKey = "fes_principal"
+
+// Principal 认证通过的调用方
+type Principal struct {
+	ID     string
+	Scheme string // 认证方式, 例如 apikey、hmac、bearer、basic
+	Roles  []string
+	Claims map[string]any
+}
+
+// Authenticator 认证方式, 请求中没有对应的凭证时返回 ErrNoCredentials
+type Authenticator interface {
+	Authenticate(ctx *fesgo.Context) (*Principal, error)
+}
+
+type AuthenticatorFunc func(ctx *fesgo.Context) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx *fesgo.Context) (*Principal, error) {
+	return f(ctx)
+}
+
+type Config struct {
+	// Authenticators 按顺序尝试, 凭证存在但校验失败时不再尝试后面的方式
+	Authenticators []Authenticator
+	// Optional 没有任何凭证时也放行, 此时 Context 中没有 Principal
+	Optional bool
+	// ErrorHandler 认证失败时调用, 默认返回 401, 请求体超过限制时返回 413
+	ErrorHandler func(ctx *fesgo.Context, err error)
+}
+
+// New 认证中间件, 认证通过后 Principal 保存在 Context 中, 同时设置 user 为 Principal.ID
+func New(config Config) fesgo.MiddlewareFunc {
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = defaultErrorHandler
+	}
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			principal, err := authenticate(ctx, config.Authenticators)
+			if err != nil {
+				if errors.Is(err, ErrNoCredentials) && config.Optional {
+					next(ctx)
+					return
+				}
+				config.ErrorHandler(ctx, err)
+				return
+			}
+			SetPrincipal(ctx, principal)
+			next(ctx)
+		}
+	}
+}
+
+func authenticate(ctx *fesgo.Context, authenticators []Authenticator) (*Principal, error) {
+	for _, a := range authenticators {
+		principal, err := a.Authenticate(ctx)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if principal == nil {
+			return nil, ErrInvalidCredentials
+		}
+		return principal, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+func defaultErrorHandler(ctx *fesgo.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || errors.Is(err, ErrBodyTooLarge) {
+		ctx.String(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+		return
+	}
+	ctx.String(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+}
+
+func SetPrincipal(ctx *fesgo.Context, principal *Principal) {
+	ctx.Set(PrincipalKey, principal)
+	ctx.Set("user", principal.ID)
+}
+
+// GetPrincipal 获取认证通过的调用方, 没有认证时返回 false
+func GetPrincipal(ctx *fesgo.Context) (*Principal, bool) {
+	value, ok := ctx.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
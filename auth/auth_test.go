@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/dalefeng/fesgo"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var partnerSecret = []byte("partner-secret")
+
+func newEngine(config Config) *fesgo.Engine {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(config))
+	handle := func(ctx *fesgo.Context) {
+		body, _ := io.ReadAll(ctx.R.Body)
+		principal, ok := GetPrincipal(ctx)
+		if !ok {
+			ctx.String(http.StatusOK, "anonymous")
+			return
+		}
+		ctx.String(http.StatusOK, "%s:%s:%s", principal.Scheme, principal.ID, body)
+	}
+	g.Get("/orders", handle)
+	g.Post("/orders", handle)
+	return engine
+}
+
+func TestMiddleware(t *testing.T) {
+	apiKey := &APIKey{
+		Query: "api_key",
+		Lookup: func(key string) (*Principal, error) {
+			if key == "k1" {
+				return &Principal{ID: "partner"}, nil
+			}
+			return nil, nil
+		},
+	}
+	bearer := &Bearer{
+		Validate: func(token string) (*Principal, error) {
+			if token == "t1" {
+				return &Principal{ID: "feng"}, nil
+			}
+			return nil, errors.New("bad token")
+		},
+	}
+	basic := Basic(func(user, password string) bool {
+		return fesgo.CheckPassword("123456", password) && user == "admin"
+	})
+	engine := newEngine(Config{Authenticators: []Authenticator{apiKey, bearer, basic}})
+
+	testCase := []struct {
+		name   string
+		url    string
+		header map[string]string
+		status int
+		body   string
+	}{
+		{"api key header", "/api/orders", map[string]string{"X-API-Key": "k1"}, http.StatusOK, "apikey:partner:"},
+		{"api key query", "/api/orders?api_key=k1", nil, http.StatusOK, "apikey:partner:"},
+		{"api key invalid", "/api/orders", map[string]string{"X-API-Key": "k2"}, http.StatusUnauthorized, ""},
+		{"bearer", "/api/orders", map[string]string{"Authorization": "Bearer t1"}, http.StatusOK, "bearer:feng:"},
+		{"bearer invalid", "/api/orders", map[string]string{"Authorization": "Bearer t2"}, http.StatusUnauthorized, ""},
+		{"basic", "/api/orders", map[string]string{"Authorization": fesgo.BasicAuth("admin", "123456")}, http.StatusOK, "basic:admin:"},
+		{"no credentials", "/api/orders", nil, http.StatusUnauthorized, ""},
+		// 凭证无效时不再尝试后面的认证方式
+		{"invalid stops", "/api/orders", map[string]string{"X-API-Key": "k2", "Authorization": "Bearer t1"}, http.StatusUnauthorized, ""},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			if tc.body != "" {
+				assert.Equal(t, tc.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOptional(t *testing.T) {
+	engine := newEngine(Config{Authenticators: []Authenticator{&APIKey{
+		Lookup: func(key string) (*Principal, error) { return nil, nil },
+	}}, Optional: true})
+	r := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, "anonymous", w.Body.String())
+
+	r.Header.Set("X-API-Key", "k1")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestHMAC(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	h := &HMAC{
+		Secret: func(keyID string) ([]byte, *Principal, error) {
+			if keyID == "partner" {
+				return partnerSecret, &Principal{ID: "partner"}, nil
+			}
+			return nil, nil, nil
+		},
+		Nonces: NewMemoryNonceStore(),
+		now:    func() time.Time { return now },
+	}
+	engine := newEngine(Config{Authenticators: []Authenticator{h}})
+
+	request := func(keyID string, ts time.Time, body string, secret []byte) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/orders?id=1", strings.NewReader(body))
+		timestamp := strconv.FormatInt(ts.Unix(), 10)
+		r.Header.Set(HeaderKeyID, keyID)
+		r.Header.Set(HeaderTimestamp, timestamp)
+		r.Header.Set(HeaderSignature, hex.EncodeToString(Sign(secret, StringToSign(r, timestamp, []byte(body)))))
+		return r
+	}
+	do := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+
+	r := request("partner", now, `{"amount":1}`, partnerSecret)
+	w := do(r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	// 签名之后 body 仍然可以读取
+	assert.Equal(t, `hmac:partner:{"amount":1}`, w.Body.String())
+
+	// 重放
+	replay := request("partner", now, `{"amount":1}`, partnerSecret)
+	assert.Equal(t, http.StatusUnauthorized, do(replay).Code)
+	// 签名改为大写后重放
+	replay = request("partner", now, `{"amount":1}`, partnerSecret)
+	replay.Header.Set(HeaderSignature, strings.ToUpper(replay.Header.Get(HeaderSignature)))
+	assert.Equal(t, http.StatusUnauthorized, do(replay).Code)
+
+	// 篡改 body
+	tampered := request("partner", now.Add(time.Second), `{"amount":1}`, partnerSecret)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"amount":100}`))
+	assert.Equal(t, http.StatusUnauthorized, do(tampered).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, do(request("partner", now.Add(-10*time.Minute), "", partnerSecret)).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(request("partner", now, "a", []byte("wrong"))).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(request("unknown", now, "a", partnerSecret)).Code)
+
+	// 客户端签名
+	h.now = nil
+	r = httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader("hello"))
+	assert.NoError(t, SignRequest(r, "partner", partnerSecret))
+	w = do(r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hmac:partner:hello", w.Body.String())
+}
+
+func TestHMACBodyLimit(t *testing.T) {
+	h := &HMAC{
+		Secret: func(keyID string) ([]byte, *Principal, error) {
+			return partnerSecret, &Principal{ID: keyID}, nil
+		},
+	}
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{Authenticators: []Authenticator{h}}))
+	g.Post("/orders", func(ctx *fesgo.Context) {
+		if _, err := io.ReadAll(ctx.R.Body); err != nil {
+			ctx.String(fesgo.BodyErrorStatus(err), err.Error())
+			return
+		}
+		ctx.String(http.StatusOK, "ok")
+	}, fesgo.BodyLimit(4))
+
+	// 签名时读取的 body 放回后, 路由级的 BodyLimit 仍然生效
+	r := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"amount":1}`))
+	assert.NoError(t, SignRequest(r, "partner", partnerSecret))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestSharedPrincipal(t *testing.T) {
+	// Lookup 返回共享的 Principal 时不能被修改
+	shared := &Principal{ID: "partner"}
+	engine := newEngine(Config{Authenticators: []Authenticator{&APIKey{
+		Lookup: func(key string) (*Principal, error) {
+			return shared, nil
+		},
+	}}})
+	r := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	r.Header.Set("X-API-Key", "k1")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, "apikey:partner:", w.Body.String())
+	assert.Equal(t, "", shared.Scheme)
+}
+
+func TestHMACBodyTooLarge(t *testing.T) {
+	h := &HMAC{
+		Secret: func(keyID string) ([]byte, *Principal, error) {
+			return partnerSecret, &Principal{ID: keyID}, nil
+		},
+		MaxBodySize: 4,
+	}
+	engine := newEngine(Config{Authenticators: []Authenticator{h}})
+	r := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"amount":1}`))
+	assert.NoError(t, SignRequest(r, "partner", partnerSecret))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// 引擎的 MaxBodyBytes 在签名读取 body 时生效
+	h.MaxBodySize = 0
+	engine.MaxBodyBytes = 4
+	r = httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"amount":1}`))
+	assert.NoError(t, SignRequest(r, "partner", partnerSecret))
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"github.com/dalefeng/fesgo"
+	"strings"
+)
+
+// Bearer 从 Authorization: Bearer <token> 中获取 token
+type Bearer struct {
+	// Validate 校验 token, 无效时返回 nil 或错误
+	Validate func(token string) (*Principal, error)
+}
+
+func (b *Bearer) Authenticate(ctx *fesgo.Context) (*Principal, error) {
+	scheme, token, ok := strings.Cut(ctx.R.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, ErrNoCredentials
+	}
+	principal, err := b.Validate(strings.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+	if principal == nil {
+		return nil, ErrInvalidCredentials
+	}
+	p := *principal
+	if p.Scheme == "" {
+		p.Scheme = "bearer"
+	}
+	return &p, nil
+}
+
+// Basic HTTP Basic 认证, 校验可以使用 fesgo.CheckPassword
+func Basic(validate func(user, password string) bool) Authenticator {
+	return AuthenticatorFunc(func(ctx *fesgo.Context) (*Principal, error) {
+		user, password, ok := ctx.R.BasicAuth()
+		if !ok {
+			return nil, ErrNoCredentials
+		}
+		if !validate(user, password) {
+			return nil, ErrInvalidCredentials
+		}
+		return &Principal{ID: user, Scheme: "basic"}, nil
+	})
+}
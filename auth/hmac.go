@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/dalefeng/fesgo"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSignatureInvalid = errors.New("auth: invalid signature")
+	ErrSignatureExpired = errors.New("auth: signature timestamp out of window")
+	ErrReplay           = errors.New("auth: replayed request")
+	ErrBodyTooLarge     = errors.New("auth: request body too large to sign")
+)
+
+const (
+	HeaderKeyID     = "X-Key-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+)
+
+// HMAC 请求签名, 签名内容为 method、path 和查询参数、时间戳、body 的 sha256, 以换行连接,
+// 见 StringToSign, 签名为 hex(hmac-sha256(secret, StringToSign))
+type HMAC struct {
+	// Secret 查找 key id 对应的密钥和调用方, 不存在时返回 nil
+	Secret func(keyID string) ([]byte, *Principal, error)
+	// Window 时间戳允许的误差, 默认 5 分钟
+	Window time.Duration
+	// Nonces 记录窗口内已使用的签名, 拒绝重放的请求, 为空时只校验时间戳
+	Nonces NonceStore
+	// MaxBodySize 计算签名时读取 body 的上限, 默认 10MB
+	MaxBodySize int64
+
+	now func() time.Time
+}
+
+func (h *HMAC) Authenticate(ctx *fesgo.Context) (*Principal, error) {
+	keyID := ctx.R.Header.Get(HeaderKeyID)
+	signature := ctx.R.Header.Get(HeaderSignature)
+	if keyID == "" || signature == "" {
+		return nil, ErrNoCredentials
+	}
+	timestamp := ctx.R.Header.Get(HeaderTimestamp)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrSignatureInvalid
+	}
+	window := h.window()
+	now := time.Now()
+	if h.now != nil {
+		now = h.now()
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > window || d < -window {
+		return nil, ErrSignatureExpired
+	}
+	secret, principal, err := h.Secret(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || principal == nil {
+		return nil, ErrInvalidCredentials
+	}
+	body, err := h.readBody(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expected := Sign(secret, StringToSign(ctx.R, timestamp, body))
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, expected) {
+		return nil, ErrSignatureInvalid
+	}
+	if h.Nonces != nil {
+		// 时间戳在前后两个窗口内都有效, 使用计算出的签名, 避免改变十六进制大小写绕过检查
+		ok, err := h.Nonces.Add(keyID+":"+hex.EncodeToString(expected), 2*window)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrReplay
+		}
+	}
+	p := *principal
+	if p.Scheme == "" {
+		p.Scheme = "hmac"
+	}
+	return &p, nil
+}
+
+func (h *HMAC) window() time.Duration {
+	if h.Window <= 0 {
+		return 5 * time.Minute
+	}
+	return h.Window
+}
+
+// readBody 读取 body 计算签名, 读取后通过 ResetBody 放回请求中, 路由级的 BodyLimit 仍然生效
+func (h *HMAC) readBody(ctx *fesgo.Context) ([]byte, error) {
+	r := ctx.R
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	limit := h.MaxBodySize
+	if limit <= 0 {
+		limit = 10 << 20
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	r.Body.Close()
+	ctx.ResetBody(body)
+	return body, nil
+}
+
+// StringToSign 签名的内容
+func StringToSign(r *http.Request, timestamp string, body []byte) string {
+	path := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	hash := sha256.Sum256(body)
+	return r.Method + "\n" + path + "\n" + timestamp + "\n" + hex.EncodeToString(hash[:])
+}
+
+func Sign(secret []byte, stringToSign string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(stringToSign))
+	return mac.Sum(nil)
+}
+
+// SignRequest 客户端对请求签名, 设置 X-Key-Id、X-Timestamp 和 X-Signature
+func SignRequest(r *http.Request, keyID string, secret []byte) error {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(HeaderKeyID, keyID)
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderSignature, hex.EncodeToString(Sign(secret, StringToSign(r, timestamp, body))))
+	return nil
+}
+
+// NonceStore 记录已使用的签名
+type NonceStore interface {
+	// Add 记录 nonce, 已经存在时返回 false
+	Add(nonce string, ttl time.Duration) (bool, error)
+}
+
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	gcAt   time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *MemoryNonceStore) Add(nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.gcAt) {
+		for k, expiry := range s.nonces {
+			if now.After(expiry) {
+				delete(s.nonces, k)
+			}
+		}
+		s.gcAt = now.Add(time.Minute)
+	}
+	if expiry, ok := s.nonces[nonce]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package fesgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckPassword(t *testing.T) {
+	bcryptHash, err := HashPassword("secret")
+	assert.NoError(t, err)
+	argon2Hash, err := HashPasswordArgon2("secret")
+	assert.NoError(t, err)
+
+	testCase := []struct {
+		name     string
+		stored   string
+		password string
+		want     bool
+	}{
+		{"plain", "secret", "secret", true},
+		{"plain wrong", "secret", "secret1", false},
+		{"bcrypt", bcryptHash, "secret", true},
+		{"bcrypt wrong", bcryptHash, "wrong", false},
+		{"argon2", argon2Hash, "secret", true},
+		{"argon2 wrong", argon2Hash, "wrong", false},
+		{"argon2 invalid", "$argon2id$v=19$m=1$abc", "secret", false},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, CheckPassword(tc.stored, tc.password))
+		})
+	}
+}
+
+func TestAccountBasicAuth(t *testing.T) {
+	hash, _ := HashPassword("123456")
+	unAuth := 0
+	engine := NewEngine()
+	g := engine.Group("api")
+	account := &Account{Users: map[string]string{"feng": hash}, Realm: "admin"}
+	g.Get("/user", func(ctx *Context) {
+		user, _ := ctx.Get("user")
+		ctx.String(http.StatusOK, "%v", user)
+	}, account.BasicAuth)
+	custom := &Account{
+		Validator: func(user, password string) bool {
+			return user == "db" && password == "pass"
+		},
+		UnAuthHandler: func(ctx *Context) {
+			unAuth++
+			ctx.String(http.StatusForbidden, "denied")
+		},
+	}
+	g.Get("/db", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	}, custom.BasicAuth)
+
+	testCase := []struct {
+		name   string
+		path   string
+		auth   string
+		status int
+	}{
+		{"ok", "/api/user", BasicAuth("feng", "123456"), http.StatusOK},
+		{"wrong password", "/api/user", BasicAuth("feng", "1234567"), http.StatusUnauthorized},
+		{"unknown user", "/api/user", BasicAuth("dale", "123456"), http.StatusUnauthorized},
+		{"no auth", "/api/user", "", http.StatusUnauthorized},
+		{"validator", "/api/db", BasicAuth("db", "pass"), http.StatusOK},
+		{"validator wrong password", "/api/db", BasicAuth("db", "wrong"), http.StatusForbidden},
+		{"validator no auth", "/api/db", "", http.StatusForbidden},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.auth != "" {
+				r.Header.Set("Authorization", tc.auth)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="admin", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+	// 自定义的未授权处理在每种失败情况下都会调用
+	assert.Equal(t, 2, unAuth)
+}
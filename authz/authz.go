@@ -0,0 +1,239 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dalefeng/fesgo"
+	"github.com/dalefeng/fesgo/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoSubject = errors.New("authz: no authenticated subject")
+	ErrForbidden = errors.New("authz: forbidden")
+)
+
+// Subject 当前请求的调用方
+type Subject struct {
+	ID         string
+	Roles      []string
+	Attributes map[string]any // ABAC 使用的属性, 例如 jwt 的 claims
+}
+
+// SubjectProvider 获取当前请求的调用方, 没有认证时返回 ErrNoSubject
+type SubjectProvider interface {
+	Subject(ctx *fesgo.Context) (*Subject, error)
+}
+
+type SubjectProviderFunc func(ctx *fesgo.Context) (*Subject, error)
+
+func (f SubjectProviderFunc) Subject(ctx *fesgo.Context) (*Subject, error) {
+	return f(ctx)
+}
+
+// Decision 一次授权的结果, 用于审计
+type Decision struct {
+	Subject  *Subject
+	Method   string
+	Path     string
+	Required string // 需要的权限, 例如 all(orders:read)
+	Allowed  bool
+	DryRun   bool // 拒绝的请求在 DryRun 下被放行
+	Err      error
+}
+
+// Authorizer 授权中间件, 需要在认证中间件之后执行
+// 认证是组中间件时需要设置 Engine.GroupMiddlewareFirst, 否则路由级的 Require 先于认证执行
+type Authorizer struct {
+	// Provider 默认从 auth.Principal 或 jwt_claims 中获取角色, 见 ClaimsProvider
+	Provider SubjectProvider
+	// ForbiddenHandler 拒绝时调用, 默认没有认证返回 401, 没有权限返回 403
+	ForbiddenHandler func(ctx *fesgo.Context, err error)
+	// DryRun 只记录拒绝的请求, 不拦截, 用于上线新的策略前观察
+	DryRun bool
+	// Audit 每次授权后调用, 为空时 DryRun 下拒绝的请求记录到日志
+	Audit func(ctx *fesgo.Context, decision Decision)
+
+	mu     sync.RWMutex
+	policy *Policy
+}
+
+// Default 包级别的 Require 等函数使用的 Authorizer
+var Default = New(nil)
+
+func New(policy *Policy) *Authorizer {
+	return &Authorizer{policy: policy}
+}
+
+// SetPolicy 替换策略, 可以在运行时重新加载
+func (a *Authorizer) SetPolicy(policy *Policy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policy = policy
+}
+
+func (a *Authorizer) Policy() *Policy {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.policy
+}
+
+// Require 需要拥有所有权限
+func (a *Authorizer) Require(permissions ...string) fesgo.MiddlewareFunc {
+	return a.RequireFunc(fmt.Sprintf("all(%s)", strings.Join(permissions, ",")), func(ctx *fesgo.Context, s *Subject) bool {
+		policy := a.Policy()
+		for _, permission := range permissions {
+			if !policy.Allowed(s.Roles, permission) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// RequireAny 拥有任一权限即可
+func (a *Authorizer) RequireAny(permissions ...string) fesgo.MiddlewareFunc {
+	return a.RequireFunc(fmt.Sprintf("any(%s)", strings.Join(permissions, ",")), func(ctx *fesgo.Context, s *Subject) bool {
+		policy := a.Policy()
+		for _, permission := range permissions {
+			if policy.Allowed(s.Roles, permission) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequireRole 拥有任一角色即可
+func (a *Authorizer) RequireRole(roles ...string) fesgo.MiddlewareFunc {
+	return a.RequireFunc(fmt.Sprintf("role(%s)", strings.Join(roles, ",")), func(ctx *fesgo.Context, s *Subject) bool {
+		for _, role := range roles {
+			for _, r := range s.Roles {
+				if r == role {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// RequireFunc 自定义条件, 可以根据 Subject.Attributes 和请求实现 ABAC, name 用于审计
+func (a *Authorizer) RequireFunc(name string, allow func(ctx *fesgo.Context, s *Subject) bool) fesgo.MiddlewareFunc {
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			decision := Decision{Method: ctx.R.Method, Path: ctx.R.URL.Path, Required: name, DryRun: a.DryRun}
+			provider := a.Provider
+			if provider == nil {
+				provider = ClaimsProvider{}
+			}
+			s, err := provider.Subject(ctx)
+			if err == nil {
+				decision.Subject = s
+				if allow(ctx, s) {
+					decision.Allowed = true
+				} else {
+					err = ErrForbidden
+				}
+			}
+			decision.Err = err
+			a.audit(ctx, decision)
+			if err != nil && !a.DryRun {
+				a.forbidden(ctx, err)
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+func (a *Authorizer) audit(ctx *fesgo.Context, decision Decision) {
+	if a.Audit != nil {
+		a.Audit(ctx, decision)
+		return
+	}
+	if decision.DryRun && !decision.Allowed && ctx.Logger != nil {
+		id := ""
+		if decision.Subject != nil {
+			id = decision.Subject.ID
+		}
+		ctx.Logger.Infow("authz dry run deny", "method", decision.Method, "path", decision.Path,
+			"subject", id, "required", decision.Required, "error", decision.Err)
+	}
+}
+
+func (a *Authorizer) forbidden(ctx *fesgo.Context, err error) {
+	if a.ForbiddenHandler != nil {
+		a.ForbiddenHandler(ctx, err)
+		return
+	}
+	status := http.StatusForbidden
+	if errors.Is(err, ErrNoSubject) {
+		status = http.StatusUnauthorized
+	}
+	ctx.String(status, http.StatusText(status))
+}
+
+func Require(permissions ...string) fesgo.MiddlewareFunc {
+	return Default.Require(permissions...)
+}
+
+func RequireAny(permissions ...string) fesgo.MiddlewareFunc {
+	return Default.RequireAny(permissions...)
+}
+
+func RequireRole(roles ...string) fesgo.MiddlewareFunc {
+	return Default.RequireRole(roles...)
+}
+
+// ClaimsProvider 依次从 auth.Principal 和 jwt_claims 中获取调用方,
+// claims 中的角色可以是字符串数组, 也可以是逗号或空格分隔的字符串
+type ClaimsProvider struct {
+	RolesClaim string // 默认 roles
+}
+
+func (p ClaimsProvider) Subject(ctx *fesgo.Context) (*Subject, error) {
+	if principal, ok := auth.GetPrincipal(ctx); ok {
+		return &Subject{ID: principal.ID, Roles: principal.Roles, Attributes: principal.Claims}, nil
+	}
+	value, ok := ctx.Get("jwt_claims")
+	if !ok {
+		return nil, ErrNoSubject
+	}
+	claims, ok := value.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrNoSubject
+	}
+	name := p.RolesClaim
+	if name == "" {
+		name = "roles"
+	}
+	s := &Subject{Roles: toRoles(claims[name]), Attributes: claims}
+	if sub, ok := claims["sub"]; ok && sub != nil {
+		s.ID = fmt.Sprint(sub)
+	}
+	return s, nil
+}
+
+func toRoles(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	case []string:
+		return v
+	case []any:
+		roles := make([]string, 0, len(v))
+		for _, role := range v {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
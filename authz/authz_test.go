@@ -0,0 +1,154 @@
+package authz
+
+import (
+	"github.com/dalefeng/fesgo"
+	"github.com/dalefeng/fesgo/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const policyYAML = `
+roles:
+  viewer:
+    permissions: ["orders:read"]
+  clerk:
+    permissions: ["orders:write"]
+    inherits: [viewer]
+  admin:
+    permissions: ["*"]
+`
+
+func loadTestPolicy(t *testing.T) *Policy {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(policyYAML), 0644))
+	policy, err := LoadPolicy(path)
+	assert.NoError(t, err)
+	return policy
+}
+
+func TestMatch(t *testing.T) {
+	testCase := []struct {
+		granted  string
+		required string
+		want     bool
+	}{
+		{"orders:read", "orders:read", true},
+		{"orders:read", "orders:write", false},
+		{"orders:*", "orders:write", true},
+		{"orders:*", "ordersx:write", false},
+		{"*", "users:delete", true},
+	}
+	for _, tc := range testCase {
+		assert.Equal(t, tc.want, Match(tc.granted, tc.required), tc.granted+" "+tc.required)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy := loadTestPolicy(t)
+	assert.True(t, policy.Allowed([]string{"clerk"}, "orders:read"))
+	assert.False(t, policy.Allowed([]string{"viewer"}, "orders:write"))
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.json")
+	os.WriteFile(path, []byte(`{"roles":{"a":{"inherits":["b"]}}}`), 0644)
+	_, err := LoadPolicy(path)
+	assert.Error(t, err)
+	path = filepath.Join(dir, "policy.toml")
+	os.WriteFile(path, []byte("[roles.ops]\npermissions = [\"servers:*\"]\n"), 0644)
+	policy, err = LoadPolicy(path)
+	assert.NoError(t, err)
+	assert.True(t, policy.Allowed([]string{"ops"}, "servers:restart"))
+}
+
+func TestRequire(t *testing.T) {
+	authorizer := New(loadTestPolicy(t))
+	var decisions []Decision
+	authorizer.Audit = func(ctx *fesgo.Context, decision Decision) {
+		decisions = append(decisions, decision)
+	}
+	engine := fesgo.NewEngine()
+	engine.GroupMiddlewareFirst = true
+	g := engine.Group("api")
+	g.Use(func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			switch ctx.R.Header.Get("X-Test-User") {
+			case "jwt":
+				ctx.Set("jwt_claims", jwt.MapClaims{"sub": "1", "roles": []any{"viewer"}, "tenant": "t1"})
+			case "principal":
+				auth.SetPrincipal(ctx, &auth.Principal{ID: "2", Roles: []string{"clerk"}})
+			}
+			next(ctx)
+		}
+	})
+	ok := func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "ok")
+	}
+	g.Get("/orders", ok, authorizer.Require("orders:read"))
+	g.Post("/orders", ok, authorizer.Require("orders:read", "orders:write"))
+	g.Get("/admin", ok, authorizer.RequireRole("admin"))
+	g.Get("/tenant", ok, authorizer.RequireFunc("tenant", func(ctx *fesgo.Context, s *Subject) bool {
+		return s.Attributes["tenant"] == ctx.GetDefaultQuery("tenant", "")
+	}))
+
+	testCase := []struct {
+		name   string
+		method string
+		url    string
+		user   string
+		status int
+	}{
+		{"jwt read", http.MethodGet, "/api/orders", "jwt", http.StatusOK},
+		{"jwt write", http.MethodPost, "/api/orders", "jwt", http.StatusForbidden},
+		{"principal write", http.MethodPost, "/api/orders", "principal", http.StatusOK},
+		{"anonymous", http.MethodGet, "/api/orders", "", http.StatusUnauthorized},
+		{"role", http.MethodGet, "/api/admin", "principal", http.StatusForbidden},
+		{"abac allow", http.MethodGet, "/api/tenant?tenant=t1", "jwt", http.StatusOK},
+		{"abac deny", http.MethodGet, "/api/tenant?tenant=t2", "jwt", http.StatusForbidden},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.url, nil)
+			r.Header.Set("X-Test-User", tc.user)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+	assert.Len(t, decisions, len(testCase))
+	assert.Equal(t, "all(orders:read,orders:write)", decisions[1].Required)
+	assert.Equal(t, ErrForbidden, decisions[1].Err)
+
+	// DryRun 下拒绝的请求被放行, 但会记录
+	authorizer.DryRun = true
+	decisions = nil
+	r := httptest.NewRequest(http.MethodPost, "/api/orders", nil)
+	r.Header.Set("X-Test-User", "jwt")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, decisions[0].Allowed)
+	assert.True(t, decisions[0].DryRun)
+}
+
+func TestDefault(t *testing.T) {
+	Default.SetPolicy(loadTestPolicy(t))
+	defer Default.SetPolicy(nil)
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Get("/orders", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "ok")
+	}, Require("orders:read"), func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			ctx.Set("jwt_claims", jwt.MapClaims{"roles": "admin"})
+			next(ctx)
+		}
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
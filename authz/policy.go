@@ -0,0 +1,108 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy 角色和权限, 权限格式为 resource:action, 支持 orders:* 和 *
+type Policy struct {
+	Roles map[string]*Role `json:"roles" yaml:"roles" toml:"roles"`
+}
+
+type Role struct {
+	Permissions []string `json:"permissions" yaml:"permissions" toml:"permissions"`
+	Inherits    []string `json:"inherits" yaml:"inherits" toml:"inherits"` // 继承其他角色的权限
+}
+
+// LoadPolicy 从文件加载, 根据扩展名支持 json、yaml 和 toml
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, policy)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, policy)
+	case ".toml":
+		err = toml.Unmarshal(data, policy)
+	default:
+		return nil, fmt.Errorf("authz: unsupported policy file %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate 检查继承的角色是否存在
+func (p *Policy) Validate() error {
+	for name, role := range p.Roles {
+		if role == nil {
+			return fmt.Errorf("authz: role %s is empty", name)
+		}
+		for _, parent := range role.Inherits {
+			if _, ok := p.Roles[parent]; !ok {
+				return fmt.Errorf("authz: role %s inherits unknown role %s", name, parent)
+			}
+		}
+	}
+	return nil
+}
+
+// Permissions 角色拥有的所有权限, 包括继承的权限
+func (p *Policy) Permissions(roles []string) []string {
+	if p == nil {
+		return nil
+	}
+	var permissions []string
+	visited := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		role, ok := p.Roles[name]
+		if visited[name] || !ok || role == nil {
+			return
+		}
+		visited[name] = true
+		permissions = append(permissions, role.Permissions...)
+		for _, parent := range role.Inherits {
+			walk(parent)
+		}
+	}
+	for _, name := range roles {
+		walk(name)
+	}
+	return permissions
+}
+
+// Allowed 角色是否拥有权限
+func (p *Policy) Allowed(roles []string, permission string) bool {
+	for _, granted := range p.Permissions(roles) {
+		if Match(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 已有的权限是否包含需要的权限, * 匹配所有, orders:* 匹配 orders 下的所有操作
+func Match(granted, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+	if strings.HasSuffix(granted, ":*") {
+		return strings.HasPrefix(required, granted[:len(granted)-1])
+	}
+	return false
+}
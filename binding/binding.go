@@ -4,6 +4,20 @@ import (
 	"net/http"
 )
 
+const (
+	MIMEHTML     = "text/html"
+	MIMEPLAIN    = "text/plain"
+	MIMEJSON     = "application/json"
+	MIMEXML      = "application/xml"
+	MIMEXML2     = "text/xml"
+	MIMEPROTOBUF = "application/x-protobuf"
+	MIMEMSGPACK  = "application/x-msgpack"
+	MIMEMSGPACK2 = "application/msgpack"
+	MIMEYAML     = "application/x-yaml"
+	MIMEYAML2    = "application/yaml"
+	MIMETOML     = "application/toml"
+)
+
 type Binding interface {
 	Name() string
 	Bind(*http.Request, any) error
@@ -11,3 +25,25 @@ type Binding interface {
 
 var JSON = JsonBinding{}
 var XML = XmlBinding{}
+var ProtoBuf = ProtoBufBinding{}
+var MsgPack = MsgPackBinding{}
+var YAML = YamlBinding{}
+var TOML = TomlBinding{}
+
+// Default 根据请求的 Content-Type 选择绑定器, 未知类型按 json 处理
+func Default(contentType string) Binding {
+	switch contentType {
+	case MIMEXML, MIMEXML2:
+		return XML
+	case MIMEPROTOBUF:
+		return ProtoBuf
+	case MIMEMSGPACK, MIMEMSGPACK2:
+		return MsgPack
+	case MIMEYAML, MIMEYAML2:
+		return YAML
+	case MIMETOML:
+		return TOML
+	default:
+		return JSON
+	}
+}
@@ -0,0 +1,92 @@
+package binding
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type user struct {
+	Name string `msgpack:"name" yaml:"name" toml:"name" validate:"required"`
+	Age  int    `msgpack:"age" yaml:"age" toml:"age"`
+}
+
+func TestDefault(t *testing.T) {
+	testCase := []struct {
+		contentType string
+		want        Binding
+	}{
+		{contentType: "", want: JSON},
+		{contentType: MIMEJSON, want: JSON},
+		{contentType: MIMEXML2, want: XML},
+		{contentType: MIMEPROTOBUF, want: ProtoBuf},
+		{contentType: MIMEMSGPACK2, want: MsgPack},
+		{contentType: MIMEYAML2, want: YAML},
+		{contentType: MIMETOML, want: TOML},
+	}
+	for _, tc := range testCase {
+		assert.Equal(t, tc.want, Default(tc.contentType))
+	}
+}
+
+func TestProtoBufBinding(t *testing.T) {
+	body, err := proto.Marshal(wrapperspb.String("feng"))
+	assert.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+
+	msg := &wrapperspb.StringValue{}
+	assert.NoError(t, ProtoBuf.Bind(r, msg))
+	assert.Equal(t, "feng", msg.GetValue())
+
+	r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	assert.Error(t, ProtoBuf.Bind(r, &user{}))
+}
+
+func TestMsgPackBinding(t *testing.T) {
+	body, err := msgpack.Marshal(&user{Name: "feng", Age: 18})
+	assert.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+
+	u := &user{}
+	assert.NoError(t, MsgPack.Bind(r, u))
+	assert.Equal(t, &user{Name: "feng", Age: 18}, u)
+
+	body, _ = msgpack.Marshal(&user{Age: 18})
+	r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	assert.Error(t, MsgPack.Bind(r, &user{}))
+}
+
+func TestTextBinding(t *testing.T) {
+	testCase := []struct {
+		name    string
+		binding Binding
+		body    string
+		want    *user
+		wantErr bool
+	}{
+		{name: "yaml", binding: YAML, body: "name: feng\nage: 18\n", want: &user{Name: "feng", Age: 18}},
+		{name: "yaml invalid", binding: YAML, body: "age: 18\n", wantErr: true},
+		{name: "toml", binding: TOML, body: "name = 'feng'\nage = 18\n", want: &user{Name: "feng", Age: 18}},
+		{name: "toml invalid", binding: TOML, body: "age = 18\n", wantErr: true},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			u := &user{}
+			err := tc.binding.Bind(r, u)
+			if tc.wantErr {
+				assert.Error(tt, err)
+				return
+			}
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.want, u)
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package binding
 
 import (
-	"encoding/json"
 	"errors"
+	"github.com/dalefeng/fesgo/codec"
 	"net/http"
 )
 
@@ -22,7 +22,7 @@ func (j JsonBinding) Bind(r *http.Request, obj any) error {
 	if body == nil {
 		return errors.New("invalid request")
 	}
-	decoder := json.NewDecoder(body)
+	decoder := codec.JSON.NewDecoder(body)
 	if j.DisallowUnknownFields {
 		decoder.DisallowUnknownFields()
 	}
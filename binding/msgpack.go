@@ -0,0 +1,30 @@
+package binding
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type MsgPackBinding struct {
+}
+
+func (m MsgPackBinding) Name() string {
+	return "msgpack"
+}
+
+func (m MsgPackBinding) Bind(r *http.Request, obj any) error {
+	body := r.Body
+	if body == nil {
+		return errors.New("invalid request")
+	}
+	defer body.Close()
+	decoder := msgpack.NewDecoder(body)
+	err := decoder.Decode(obj)
+	if err != nil {
+		return err
+	}
+
+	return Validate.ValidateStruct(obj)
+}
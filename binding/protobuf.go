@@ -0,0 +1,34 @@
+package binding
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type ProtoBufBinding struct {
+}
+
+func (p ProtoBufBinding) Name() string {
+	return "protobuf"
+}
+
+func (p ProtoBufBinding) Bind(r *http.Request, obj any) error {
+	body := r.Body
+	if body == nil {
+		return errors.New("invalid request")
+	}
+	defer body.Close()
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return errors.New("obj is not proto.Message")
+	}
+	buf, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	// protobuf 生成的结构体没有校验标签, 不做结构体校验
+	return proto.Unmarshal(buf, msg)
+}
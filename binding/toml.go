@@ -0,0 +1,30 @@
+package binding
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+type TomlBinding struct {
+}
+
+func (t TomlBinding) Name() string {
+	return "toml"
+}
+
+func (t TomlBinding) Bind(r *http.Request, obj any) error {
+	body := r.Body
+	if body == nil {
+		return errors.New("invalid request")
+	}
+	defer body.Close()
+	decoder := toml.NewDecoder(body)
+	err := decoder.Decode(obj)
+	if err != nil {
+		return err
+	}
+
+	return Validate.ValidateStruct(obj)
+}
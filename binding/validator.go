@@ -54,7 +54,7 @@ func (d *defaultValidator) ValidateStruct(obj any) error {
 	errs := make(SliceValidationError, 0)
 	switch elem.Kind() {
 	case reflect.Struct:
-		return d.validateStruct(elem)
+		return d.validateStruct(elem.Interface())
 	case reflect.Slice, reflect.Array:
 		count := elem.Len()
 		for i := 0; i < count; i++ {
@@ -0,0 +1,30 @@
+package binding
+
+import (
+	"errors"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+type YamlBinding struct {
+}
+
+func (y YamlBinding) Name() string {
+	return "yaml"
+}
+
+func (y YamlBinding) Bind(r *http.Request, obj any) error {
+	body := r.Body
+	if body == nil {
+		return errors.New("invalid request")
+	}
+	defer body.Close()
+	decoder := yaml.NewDecoder(body)
+	err := decoder.Decode(obj)
+	if err != nil {
+		return err
+	}
+
+	return Validate.ValidateStruct(obj)
+}
@@ -0,0 +1,135 @@
+package fesgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+var ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+// requestBody 包装请求体, 透明解压并在读取时按 Context 当前的限制计数
+// 限制在读取时才取值, 所以路由级中间件可以覆盖引擎级的 MaxBodyBytes
+type requestBody struct {
+	ctx      *Context
+	src      io.ReadCloser
+	encoding string
+	length   int64
+	reader   io.Reader
+	read     int64
+	err      error
+}
+
+func newRequestBody(ctx *Context, r *http.Request) *requestBody {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		encoding = ""
+	}
+	if encoding != "" {
+		// 解压后长度未知, 避免后续代码按压缩后的长度处理
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = -1
+	}
+	return &requestBody{ctx: ctx, src: r.Body, encoding: encoding, length: r.ContentLength}
+}
+
+func (b *requestBody) init() error {
+	if b.reader != nil {
+		return nil
+	}
+	switch b.encoding {
+	case "":
+		b.reader = b.src
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(b.src)
+		if err != nil {
+			return err
+		}
+		b.reader = r
+	case "deflate":
+		r, err := zlib.NewReader(b.src)
+		if err != nil {
+			return err
+		}
+		b.reader = r
+	case "br":
+		b.reader = brotli.NewReader(b.src)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, b.encoding)
+	}
+	return nil
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if err := b.init(); err != nil {
+		b.err = err
+		return 0, err
+	}
+	limit := b.ctx.maxBodyBytes
+	if limit <= 0 {
+		n, err := b.reader.Read(p)
+		b.read += int64(n)
+		return n, err
+	}
+	// Content-Length 已经超出限制时不再读取
+	if b.read > limit || b.length > limit {
+		b.err = &http.MaxBytesError{Limit: limit}
+		return 0, b.err
+	}
+	// 多读一个字节用来判断是否超出限制
+	if remain := limit - b.read + 1; int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if b.read > limit {
+		n -= int(b.read - limit)
+		b.err = &http.MaxBytesError{Limit: limit}
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *requestBody) Close() error {
+	return b.src.Close()
+}
+
+// BodyLimit 路由级请求体大小限制, 覆盖 Engine.MaxBodyBytes, n <= 0 表示不限制
+// 中间件缓存 body 后需要通过 Context.ResetBody 放回, 之后的读取仍然按这里的限制检查
+func BodyLimit(n int64) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			ctx.maxBodyBytes = n
+			next(ctx)
+		}
+	}
+}
+
+// ResetBody 中间件读取 body 后放回请求中, 之后的读取仍然按 Context 当前的限制检查
+func (c *Context) ResetBody(body []byte) {
+	c.R.Body = &requestBody{ctx: c, src: io.NopCloser(bytes.NewReader(body)), length: int64(len(body))}
+}
+
+// BodyErrorStatus 根据读取请求体或上传文件的错误返回对应的状态码
+func BodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, ErrUploadTooLarge), errors.Is(err, ErrFileTooLarge),
+		errors.Is(err, ErrTooManyFiles):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedContentEncoding), errors.Is(err, ErrFileTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusBadRequest
+	}
+}
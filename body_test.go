@@ -0,0 +1,138 @@
+package fesgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bodyUser struct {
+	Name string `json:"name"`
+}
+
+func gzipString(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestRequestBody(t *testing.T) {
+	testCase := []struct {
+		name     string
+		limit    int64
+		route    []MiddlewareFunc
+		body     []byte
+		encoding string
+		want     int
+	}{
+		{
+			name: "no limit",
+			body: []byte(`{"name":"feng"}`),
+			want: http.StatusOK,
+		},
+		{
+			name:  "too large",
+			limit: 5,
+			body:  []byte(`{"name":"feng"}`),
+			want:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:  "route override",
+			limit: 5,
+			route: []MiddlewareFunc{BodyLimit(1 << 10)},
+			body:  []byte(`{"name":"feng"}`),
+			want:  http.StatusOK,
+		},
+		{
+			name:     "gzip",
+			body:     gzipString(`{"name":"feng"}`),
+			encoding: "gzip",
+			want:     http.StatusOK,
+		},
+		{
+			name:     "gzip bomb",
+			limit:    64,
+			body:     gzipString(`{"name":"` + strings.Repeat("a", 1<<16) + `"}`),
+			encoding: "gzip",
+			want:     http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "unsupported encoding",
+			body:     []byte(`{"name":"feng"}`),
+			encoding: "compress",
+			want:     http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			engine := NewEngine()
+			engine.MaxBodyBytes = tc.limit
+			group := engine.Group("api")
+			group.Post("/user", func(ctx *Context) {
+				user := &bodyUser{}
+				if err := ctx.BindJson(user); err != nil {
+					return
+				}
+				ctx.String(http.StatusOK, user.Name)
+			}, tc.route...)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/user", bytes.NewReader(tc.body))
+			if tc.encoding != "" {
+				r.Header.Set("Content-Encoding", tc.encoding)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(tt, tc.want, w.Code)
+			if tc.want == http.StatusOK {
+				assert.Equal(tt, "feng", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestResetBody(t *testing.T) {
+	engine := NewEngine()
+	group := engine.Group("api")
+	// 组中间件先读取 body, 路由级的 BodyLimit 仍然生效
+	group.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			body, err := io.ReadAll(ctx.R.Body)
+			if err != nil {
+				ctx.String(BodyErrorStatus(err), err.Error())
+				return
+			}
+			ctx.ResetBody(body)
+			next(ctx)
+		}
+	})
+	group.Post("/user", func(ctx *Context) {
+		user := &bodyUser{}
+		if err := ctx.BindJson(user); err != nil {
+			return
+		}
+		ctx.String(http.StatusOK, user.Name)
+	}, BodyLimit(8))
+	group.Post("/large", func(ctx *Context) {
+		user := &bodyUser{}
+		if err := ctx.BindJson(user); err != nil {
+			return
+		}
+		ctx.String(http.StatusOK, user.Name)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/user", strings.NewReader(`{"name":"feng"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/large", strings.NewReader(`{"name":"feng"}`)))
+	assert.Equal(t, "feng", w.Body.String())
+}
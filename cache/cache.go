@@ -0,0 +1,299 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dalefeng/fesgo"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Store Store         // 默认 NewMemoryStore(1000)
+	TTL   time.Duration // 响应没有 Cache-Control max-age 时的缓存时间, 默认 1 分钟
+	// KeyFunc 缓存的 key, 默认 DefaultKey, 返回空字符串时不缓存
+	KeyFunc func(ctx *fesgo.Context) string
+	// MaxBodySize 超过该大小的响应直接发送, 不缓存, 默认 1MB
+	MaxBodySize int
+	// ErrorHandler 存储出错时调用, 为空时记录日志, 出错不影响请求
+	ErrorHandler func(ctx *fesgo.Context, err error)
+}
+
+// New 缓存 GET 和 HEAD 请求 200 的响应, 遵循请求和响应的 Cache-Control, 响应有 Vary 时按对应请求头的值分别缓存,
+// 同一个 key 同时只有一个请求执行 handler, 其他请求等待结果, 响应自动添加 ETag, If-None-Match 命中时返回 304
+func New(config Config) fesgo.MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = NewMemoryStore(1000)
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = DefaultKey
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	c := &cache{config: config, calls: make(map[string]*call)}
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			if ctx.R.Method != http.MethodGet && ctx.R.Method != http.MethodHead {
+				next(ctx)
+				return
+			}
+			directives := parseCacheControl(ctx.R.Header.Get("Cache-Control"))
+			if _, ok := directives["no-store"]; ok {
+				next(ctx)
+				return
+			}
+			key := config.KeyFunc(ctx)
+			if key == "" {
+				next(ctx)
+				return
+			}
+			if !noCache(directives, ctx.R.Header.Get("Pragma")) {
+				entry, err := c.get(key, ctx.R)
+				if err == nil {
+					serve(ctx, entry, true)
+					return
+				}
+				if !errors.Is(err, ErrNotFound) {
+					c.error(ctx, err)
+				}
+			}
+			c.miss(ctx, key, next)
+		}
+	}
+}
+
+// DefaultKey 请求方法和 URL, 带有 Authorization 或 Cookie 的请求不缓存
+func DefaultKey(ctx *fesgo.Context) string {
+	if ctx.R.Header.Get("Authorization") != "" || ctx.R.Header.Get("Cookie") != "" {
+		return ""
+	}
+	return ctx.R.Method + " " + ctx.R.URL.RequestURI()
+}
+
+type cache struct {
+	config Config
+	mu     sync.Mutex
+	calls  map[string]*call
+}
+
+// call 正在执行的请求, 完成后 entry 为可以共享的响应, 不能共享时为空
+// 响应有 Vary 时只共享给 Vary 请求头相同的请求
+type call struct {
+	done    chan struct{}
+	entry   *Entry
+	vary    []string
+	variant string
+}
+
+// get 读取缓存, 命中 Vary 记录时再按请求头读取对应的响应
+func (c *cache) get(key string, r *http.Request) (*Entry, error) {
+	entry, err := c.config.Store.Get(key)
+	if err != nil || len(entry.Vary) == 0 {
+		return entry, err
+	}
+	return c.config.Store.Get(variantKey(key, entry.Vary, r))
+}
+
+// miss 缓存没有命中, 第一个请求执行 handler, 相同 key 的其他请求等待它的结果
+func (c *cache) miss(ctx *fesgo.Context, key string, next fesgo.HandlerFunc) {
+	c.mu.Lock()
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-cl.done:
+		case <-ctx.R.Context().Done():
+			return
+		}
+		if cl.entry != nil && (len(cl.vary) == 0 || variantKey(key, cl.vary, ctx.R) == cl.variant) {
+			serve(ctx, cl.entry, true)
+			return
+		}
+		next(ctx)
+		return
+	}
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+
+	w := &recorder{ResponseWriter: ctx.W, max: c.config.MaxBodySize}
+	ctx.W = w
+	func() {
+		// handler panic 时恢复原来的 ResponseWriter, Recovery 才能发送错误
+		defer func() {
+			ctx.W = w.ResponseWriter
+		}()
+		next(ctx)
+	}()
+	if w.passthrough {
+		return
+	}
+	entry := w.entry()
+	ttl, ok := c.ttl(entry)
+	if ok {
+		if err := c.set(ctx.R, key, entry, ttl, cl); err != nil {
+			c.error(ctx, err)
+		}
+		cl.entry = entry
+	}
+	serve(ctx, entry, false)
+}
+
+// set 保存响应, 有 Vary 时 key 下保存 Vary 请求头, 响应保存在加上请求头值的 key 下
+func (c *cache) set(r *http.Request, key string, entry *Entry, ttl time.Duration, cl *call) error {
+	vary := parseVary(entry.Header)
+	if len(vary) == 0 {
+		return c.config.Store.Set(key, entry, ttl)
+	}
+	cl.vary = vary
+	cl.variant = variantKey(key, vary, r)
+	if err := c.config.Store.Set(key, &Entry{Vary: vary, Created: entry.Created}, ttl); err != nil {
+		return err
+	}
+	return c.config.Store.Set(cl.variant, entry, ttl)
+}
+
+// parseVary 响应的 Vary 请求头, 去重并排序
+func parseVary(header http.Header) []string {
+	seen := make(map[string]bool)
+	vary := make([]string, 0)
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			vary = append(vary, name)
+		}
+	}
+	sort.Strings(vary)
+	return vary
+}
+
+// variantKey key 加上 Vary 请求头的值
+func variantKey(key string, vary []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(key)
+	for _, name := range vary {
+		sb.WriteString("\n" + name + ": " + strings.Join(r.Header.Values(name), ", "))
+	}
+	return sb.String()
+}
+
+// ttl 根据响应判断是否可以缓存, s-maxage 优先于 max-age
+func (c *cache) ttl(entry *Entry) (time.Duration, bool) {
+	if entry.Status != http.StatusOK || entry.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	for _, name := range parseVary(entry.Header) {
+		if name == "*" {
+			return 0, false
+		}
+	}
+	directives := parseCacheControl(entry.Header.Get("Cache-Control"))
+	for _, name := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[name]; ok {
+			return 0, false
+		}
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[name]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return c.config.TTL, true
+}
+
+func (c *cache) error(ctx *fesgo.Context, err error) {
+	if c.config.ErrorHandler != nil {
+		c.config.ErrorHandler(ctx, err)
+		return
+	}
+	if ctx.Logger != nil {
+		ctx.Logger.Error(fmt.Sprintf("cache: %v", err))
+	}
+}
+
+// serve 发送响应, If-None-Match 与 ETag 一致时返回 304
+func serve(ctx *fesgo.Context, entry *Entry, hit bool) {
+	header := ctx.W.Header()
+	for k, v := range entry.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	if hit {
+		header.Set("X-Cache", "HIT")
+		header.Set("Age", strconv.Itoa(int(time.Since(entry.Created)/time.Second)))
+	} else {
+		header.Set("X-Cache", "MISS")
+	}
+	etag := header.Get("ETag")
+	if inm := ctx.R.Header.Get("If-None-Match"); inm != "" && etag != "" && etagMatch(inm, etag) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		ctx.SetStatusCode(http.StatusNotModified)
+		return
+	}
+	ctx.SetStatusCode(entry.Status)
+	if ctx.R.Method != http.MethodHead {
+		ctx.W.Write(entry.Body)
+	}
+}
+
+// etagMatch If-None-Match 使用弱比较
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func generateETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func noCache(directives map[string]string, pragma string) bool {
+	if _, ok := directives["no-cache"]; ok {
+		return true
+	}
+	if directives["max-age"] == "0" {
+		return true
+	}
+	return len(directives) == 0 && strings.EqualFold(strings.TrimSpace(pragma), "no-cache")
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		directives[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"github.com/dalefeng/fesgo"
+	"github.com/dalefeng/fesgo/compress"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(2)
+	s.Set("a", &Entry{Body: []byte("a")}, time.Minute)
+	s.Set("b", &Entry{Body: []byte("b")}, time.Minute)
+	s.Get("a")
+	s.Set("c", &Entry{Body: []byte("c")}, time.Minute)
+	// b 最久没有使用, 被淘汰
+	_, err := s.Get("b")
+	assert.Equal(t, ErrNotFound, err)
+	e, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(e.Body))
+	assert.Equal(t, 2, s.Len())
+
+	s.Set("d", &Entry{}, -time.Second)
+	_, err = s.Get("d")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 1, s.Len())
+	s.Delete("a")
+	assert.Equal(t, 0, s.Len())
+}
+
+func TestCache(t *testing.T) {
+	var calls int32
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{MaxBodySize: 64}))
+	g.Get("/report", func(ctx *fesgo.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.String(http.StatusOK, "report %d", n)
+	})
+	g.Get("/private", func(ctx *fesgo.Context) {
+		atomic.AddInt32(&calls, 1)
+		ctx.W.Header().Set("Cache-Control", "private")
+		ctx.String(http.StatusOK, "private")
+	})
+	g.Get("/large", func(ctx *fesgo.Context) {
+		atomic.AddInt32(&calls, 1)
+		ctx.String(http.StatusOK, strings.Repeat("a", 100))
+	})
+	g.Get("/missing", func(ctx *fesgo.Context) {
+		atomic.AddInt32(&calls, 1)
+		ctx.String(http.StatusNotFound, "missing")
+	})
+
+	do := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/report", nil)
+	assert.Equal(t, "report 1", w.Body.String())
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = do(http.MethodGet, "/api/report", nil)
+	assert.Equal(t, "report 1", w.Body.String())
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = do(http.MethodGet, "/api/report", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// 查询参数不同, key 不同
+	assert.Equal(t, "report 2", do(http.MethodGet, "/api/report?page=2", nil).Body.String())
+	// no-cache 重新执行并更新缓存
+	assert.Equal(t, "report 3", do(http.MethodGet, "/api/report", map[string]string{"Cache-Control": "no-cache"}).Body.String())
+	assert.Equal(t, "report 3", do(http.MethodGet, "/api/report", nil).Body.String())
+	// no-store 不读取也不写入
+	assert.Equal(t, "report 4", do(http.MethodGet, "/api/report", map[string]string{"Cache-Control": "no-store"}).Body.String())
+	assert.Equal(t, "report 5", do(http.MethodGet, "/api/report", map[string]string{"Authorization": "Bearer x"}).Body.String())
+
+	atomic.StoreInt32(&calls, 0)
+	for _, path := range []string{"/api/private", "/api/large", "/api/missing"} {
+		do(http.MethodGet, path, nil)
+		w = do(http.MethodGet, path, nil)
+		assert.NotEqual(t, "HIT", w.Header().Get("X-Cache"), path)
+	}
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+	w = do(http.MethodGet, "/api/large", nil)
+	assert.Equal(t, 100, w.Body.Len())
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/missing", nil).Code)
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	store := NewMemoryStore(10)
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{Store: store}))
+	g.Get("/a", func(ctx *fesgo.Context) {
+		ctx.W.Header().Set("Cache-Control", "public, max-age=0")
+		ctx.String(http.StatusOK, "a")
+	})
+	g.Get("/b", func(ctx *fesgo.Context) {
+		ctx.W.Header().Set("Cache-Control", "max-age=60")
+		ctx.String(http.StatusOK, "b")
+	})
+	for _, path := range []string{"/api/a", "/api/b"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	_, err := store.Get("GET /api/a")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.Get("GET /api/b")
+	assert.NoError(t, err)
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{}))
+	g.Get("/slow", func(ctx *fesgo.Context) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ctx.String(http.StatusOK, "slow")
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/slow", nil))
+			bodies[i] = w.Body.String()
+		}(i)
+	}
+	// 等待第一个请求进入 handler
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, body := range bodies {
+		assert.Equal(t, "slow", body)
+	}
+}
+
+type cacheUser struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestCacheVary(t *testing.T) {
+	var calls int32
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{}))
+	g.Get("/user", func(ctx *fesgo.Context) {
+		atomic.AddInt32(&calls, 1)
+		ctx.Negotiate(http.StatusOK, fesgo.NegotiateConfig{Data: &cacheUser{Name: "feng"}, Offered: []string{"application/json", "application/xml"}})
+	})
+
+	do := func(header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	json := map[string]string{"Accept": "application/json"}
+	xml := map[string]string{"Accept": "application/xml"}
+	assert.Equal(t, "application/json; charset=utf-8", do(json).Header().Get("Content-Type"))
+	// Accept 不同, 不能使用 JSON 的缓存
+	w := do(xml)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	w = do(xml)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	w = do(json)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// 带有 Cookie 的请求不缓存
+	assert.Empty(t, do(map[string]string{"Accept": "application/json", "Cookie": "sid=1"}).Header().Get("X-Cache"))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestCacheVaryCompress(t *testing.T) {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	// 后添加的中间件先执行, 压缩在缓存内部
+	g.Use(compress.New(compress.Config{MinLength: 1}), New(Config{}))
+	g.Get("/report", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, strings.Repeat("report", 10))
+	})
+	do := func(encoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/report", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	assert.Equal(t, "gzip", do("gzip").Header().Get("Content-Encoding"))
+	w := do("")
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, strings.Repeat("report", 10), w.Body.String())
+	w = do("gzip")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+}
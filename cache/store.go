@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("cache: not found")
+
+// Entry 缓存的响应, Vary 不为空时只记录响应的 Vary 请求头, 响应按请求头的值保存在另外的 key 下
+type Entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Created time.Time
+	Vary    []string
+}
+
+// Store 响应缓存的存储, ttl 到期后 Get 返回 ErrNotFound, 多机部署时可以使用 redis 等实现
+type Store interface {
+	Get(key string) (*Entry, error)
+	Set(key string, entry *Entry, ttl time.Duration) error
+	Delete(key string) error
+}
+
+type lruItem struct {
+	key    string
+	entry  *Entry
+	expiry time.Time
+}
+
+// MemoryStore 内存中的 LRU 缓存, 超过 MaxEntries 时淘汰最久没有使用的响应
+type MemoryStore struct {
+	MaxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{MaxEntries: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (s *MemoryStore) Get(key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	item := e.Value.(*lruItem)
+	if time.Now().After(item.expiry) {
+		s.remove(e)
+		return nil, ErrNotFound
+	}
+	s.ll.MoveToFront(e)
+	return item.entry, nil
+}
+
+func (s *MemoryStore) Set(key string, entry *Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry := time.Now().Add(ttl)
+	if e, ok := s.items[key]; ok {
+		item := e.Value.(*lruItem)
+		item.entry, item.expiry = entry, expiry
+		s.ll.MoveToFront(e)
+		return nil
+	}
+	s.items[key] = s.ll.PushFront(&lruItem{key: key, entry: entry, expiry: expiry})
+	for s.MaxEntries > 0 && s.ll.Len() > s.MaxEntries {
+		s.remove(s.ll.Back())
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+// Len 缓存的响应数量, 包括还没有淘汰的过期响应
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+func (s *MemoryStore) remove(e *list.Element) {
+	s.ll.Remove(e)
+	delete(s.items, e.Value.(*lruItem).key)
+}
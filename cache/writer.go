@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// recorder 缓冲完整的响应, 超过 max、Flush 或 Hijack 时改为直接发送, 这样的响应不缓存
+type recorder struct {
+	http.ResponseWriter
+	max         int
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *recorder) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.buf.Len()+len(b) > w.max {
+		if err := w.startPassthrough(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *recorder) startPassthrough() error {
+	w.passthrough = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *recorder) Flush() {
+	if !w.passthrough {
+		w.startPassthrough()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("cache: response does not implement http.Hijacker")
+	}
+	w.passthrough = true
+	return h.Hijack()
+}
+
+func (w *recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// entry 记录的响应, 没有 ETag 时根据内容生成
+func (w *recorder) entry() *Entry {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := w.Header().Clone()
+	body := append([]byte(nil), w.buf.Bytes()...)
+	if header.Get("ETag") == "" && status == http.StatusOK {
+		header.Set("ETag", generateETag(body))
+	}
+	if header.Get("Content-Type") == "" && len(body) > 0 {
+		header.Set("Content-Type", http.DetectContentType(body))
+	}
+	return &Entry{Status: status, Header: header, Body: body, Created: time.Now()}
+}
@@ -0,0 +1,190 @@
+package fesgo
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SetTrustedProxies 设置可信的代理, 支持 IP 和 CIDR, 只有来自可信代理的请求才使用
+// Forwarded、X-Forwarded-For 和 X-Real-IP 中的客户端 IP, 为空时不信任任何代理
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy: %s", proxy)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	e.trustedCIDRs = cidrs
+	return nil
+}
+
+func (e *Engine) isTrustedProxy(ip net.IP) bool {
+	if e == nil || ip == nil {
+		return false
+	}
+	for _, cidr := range e.trustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteIP 直接连接的对端 IP, 经过代理时是代理的 IP
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.R.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(c.R.RemoteAddr)
+	}
+	return ip
+}
+
+// ClientIP 客户端 IP, 对端是可信代理时依次从 Engine.RemoteIPHeaders 中获取,
+// 从右向左跳过可信代理, 第一个不可信的地址即为客户端 IP
+func (c *Context) ClientIP() string {
+	remoteIP := c.RemoteIP()
+	if c.engine == nil || !c.engine.isTrustedProxy(net.ParseIP(remoteIP)) {
+		return remoteIP
+	}
+	for _, name := range c.engine.RemoteIPHeaders {
+		value := c.R.Header.Values(name)
+		if len(value) == 0 {
+			continue
+		}
+		var ips []string
+		if strings.EqualFold(name, "Forwarded") {
+			ips = parseForwarded(value)
+		} else {
+			ips = strings.Split(strings.Join(value, ","), ",")
+		}
+		if ip, ok := c.engine.clientIPFromList(ips); ok {
+			return ip
+		}
+	}
+	return remoteIP
+}
+
+// Scheme 请求的协议, 对端是可信代理时使用 X-Forwarded-Proto 或 Forwarded 中的 proto
+func (c *Context) Scheme() string {
+	if c.fromTrustedProxy() {
+		proto := strings.ToLower(c.forwardedValue("X-Forwarded-Proto", "proto"))
+		if proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if c.R.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host 请求的主机名, 对端是可信代理时使用 X-Forwarded-Host 或 Forwarded 中的 host
+func (c *Context) Host() string {
+	if c.fromTrustedProxy() {
+		if host := c.forwardedValue("X-Forwarded-Host", "host"); host != "" {
+			return host
+		}
+	}
+	return c.R.Host
+}
+
+func (c *Context) fromTrustedProxy() bool {
+	return c.engine != nil && c.engine.isTrustedProxy(net.ParseIP(c.RemoteIP()))
+}
+
+// forwardedValue 离可信对端最近的代理记录的值, 左边的值可能是客户端伪造的, 优先使用 X-Forwarded-* 请求头
+// X-Forwarded-* 没有记录每一跳的地址, 只使用最右边的值;
+// Forwarded 从右向左查找, 记录中的 for 是可信代理时继续使用更左边的值
+func (c *Context) forwardedValue(header, param string) string {
+	if values := c.R.Header.Values(header); len(values) > 0 {
+		list := strings.Split(strings.Join(values, ","), ",")
+		return strings.TrimSpace(list[len(list)-1])
+	}
+	values := c.R.Header.Values("Forwarded")
+	if len(values) == 0 {
+		return ""
+	}
+	elements := strings.Split(strings.Join(values, ","), ",")
+	result := ""
+	for i := len(elements) - 1; i >= 0; i-- {
+		var forIP net.IP
+		for _, pair := range strings.Split(elements[i], ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			switch {
+			case strings.EqualFold(k, param) && v != "":
+				result = strings.Trim(v, `"`)
+			case strings.EqualFold(k, "for"):
+				forIP = net.ParseIP(forwardedFor(v))
+			}
+		}
+		if !c.engine.isTrustedProxy(forIP) {
+			break
+		}
+	}
+	return result
+}
+
+// clientIPFromList 从右向左查找第一个不可信的地址, 全部可信时返回最左边的地址, 存在不合法的地址时返回 false
+func (e *Engine) clientIPFromList(ips []string) (string, bool) {
+	if len(ips) == 0 {
+		return "", false
+	}
+	for i := len(ips) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(ips[i]))
+		if ip == nil {
+			return "", false
+		}
+		if i == 0 || !e.isTrustedProxy(ip) {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
+
+// parseForwarded 解析 RFC 7239 Forwarded 中的 for 参数, 去掉端口和 IPv6 的方括号
+func parseForwarded(values []string) []string {
+	ips := make([]string, 0)
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(k, "for") {
+					continue
+				}
+				// unknown 和 _hidden 等混淆的地址会被当作不合法的地址
+				ips = append(ips, forwardedFor(v))
+			}
+		}
+	}
+	return ips
+}
+
+// forwardedFor 去掉 for 参数的引号、端口和 IPv6 的方括号
+func forwardedFor(v string) string {
+	v = strings.Trim(v, `"`)
+	if strings.HasPrefix(v, "[") {
+		if end := strings.IndexByte(v, ']'); end > 0 {
+			v = v[1:end]
+		}
+	} else if host, _, err := net.SplitHostPort(v); err == nil {
+		v = host
+	}
+	return v
+}
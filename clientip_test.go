@@ -0,0 +1,147 @@
+package fesgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetTrustedProxies(t *testing.T) {
+	engine := NewEngine()
+	assert.NoError(t, engine.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"}))
+	assert.Len(t, engine.trustedCIDRs, 3)
+	assert.Error(t, engine.SetTrustedProxies([]string{"abc"}))
+	assert.Error(t, engine.SetTrustedProxies([]string{"10.0.0.0/33"}))
+}
+
+func TestClientIP(t *testing.T) {
+	testCase := []struct {
+		name    string
+		trusted []string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "no trusted proxies",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.1.1.1"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "untrusted remote",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "2.2.2.2:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.1.1.1"},
+			want:    "2.2.2.2",
+		},
+		{
+			name:    "x-forwarded-for",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.1.1.1"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "skip trusted hops",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "6.6.6.6, 1.1.1.1, 10.0.0.2"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "all trusted",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			want:    "10.0.0.3",
+		},
+		{
+			name:    "invalid x-forwarded-for",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "abc", "X-Real-IP": "3.3.3.3"},
+			want:    "3.3.3.3",
+		},
+		{
+			name:    "forwarded",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::17]:4711";proto=https, for=10.0.0.2:80`,
+				"X-Forwarded-For": "1.1.1.1",
+			},
+			want: "2001:db8::17",
+		},
+		{
+			name:    "forwarded unknown",
+			trusted: []string{"10.0.0.0/8"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"Forwarded": "for=unknown", "X-Forwarded-For": "1.1.1.1"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "ipv6 remote",
+			trusted: []string{"::1"},
+			remote:  "[::1]:1234",
+			headers: map[string]string{"X-Real-IP": "4.4.4.4"},
+			want:    "4.4.4.4",
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := NewEngine()
+			assert.NoError(t, engine.SetTrustedProxies(tc.trusted))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remote
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			ctx := &Context{engine: engine, R: r}
+			assert.Equal(t, tc.want, ctx.ClientIP())
+		})
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "1.1.1.1")
+	ctx := &Context{R: r}
+	assert.Equal(t, "10.0.0.1", ctx.RemoteIP())
+	assert.Equal(t, "10.0.0.1", ctx.ClientIP())
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	engine := NewEngine()
+	assert.NoError(t, engine.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	testCase := []struct {
+		name   string
+		remote string
+		header map[string]string
+		scheme string
+		host   string
+	}{
+		{name: "direct", remote: "10.0.0.1:1234", scheme: "http", host: "example.com"},
+		{name: "x-forwarded", remote: "10.0.0.1:1234", header: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "www.example.com"}, scheme: "https", host: "www.example.com"},
+		{name: "x-forwarded spoofed", remote: "10.0.0.1:1234", header: map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "evil.com, www.example.com"}, scheme: "http", host: "www.example.com"},
+		{name: "forwarded", remote: "10.0.0.1:1234", header: map[string]string{"Forwarded": `for=1.1.1.1;proto=https;host="www.example.com", for=10.0.0.2`}, scheme: "https", host: "www.example.com"},
+		{name: "forwarded spoofed", remote: "10.0.0.1:1234", header: map[string]string{"Forwarded": `proto=https;host=evil.com, for=1.1.1.1;proto=http;host=www.example.com`}, scheme: "http", host: "www.example.com"},
+		{name: "forwarded trusted hop", remote: "10.0.0.1:1234", header: map[string]string{"Forwarded": `for=1.1.1.1;proto=https;host=www.example.com, for="10.0.0.2:80";proto=http;host=internal`}, scheme: "https", host: "www.example.com"},
+		{name: "invalid proto", remote: "10.0.0.1:1234", header: map[string]string{"X-Forwarded-Proto": "ftp"}, scheme: "http", host: "example.com"},
+		{name: "untrusted", remote: "1.1.1.1:1234", header: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"}, scheme: "http", host: "example.com"},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remote
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			ctx := &Context{engine: engine, R: r}
+			assert.Equal(t, tc.scheme, ctx.Scheme())
+			assert.Equal(t, tc.host, ctx.Host())
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"github.com/dalefeng/fesgo"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Config struct {
+	// Encodings 支持的编码, 按优先级排列, 默认 br、gzip、deflate
+	Encodings []string
+	// ContentTypes 需要压缩的类型, 支持通配符, 例如 text/*、application/*+json, 为空时使用 DefaultContentTypes
+	ContentTypes []string
+	MinLength    int // 小于该长度的响应不压缩, 默认 1024
+	GzipLevel    int // gzip 和 deflate 的压缩级别, 0 使用默认级别
+	BrotliLevel  int // brotli 的压缩级别, 0 使用 4
+	// Skip 返回 true 时不压缩
+	Skip func(ctx *fesgo.Context) bool
+}
+
+// DefaultContentTypes 默认压缩的类型, 图片、视频和压缩包等已经压缩过的类型不再压缩
+var DefaultContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"application/x-yaml",
+	"application/yaml",
+	"application/toml",
+	"application/x-msgpack",
+	"application/x-protobuf",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// Default 使用默认配置
+func Default() fesgo.MiddlewareFunc {
+	return New(Config{})
+}
+
+// New 响应压缩中间件, 根据 Accept-Encoding 选择编码, 不压缩小响应、Range 请求、SSE 和已经编码的响应
+func New(config Config) fesgo.MiddlewareFunc {
+	c := newCompressor(config)
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			if c.skipRequest(ctx) {
+				next(ctx)
+				return
+			}
+			encoding := c.negotiate(ctx.R.Header.Get("Accept-Encoding"))
+			w := &responseWriter{ResponseWriter: ctx.W, c: c, encoding: encoding, head: ctx.R.Method == http.MethodHead}
+			ctx.W = w
+			defer func() {
+				w.close()
+				ctx.W = w.ResponseWriter
+			}()
+			next(ctx)
+		}
+	}
+}
+
+type compressor struct {
+	config    Config
+	encodings []string
+	types     []string
+	minLength int
+	pools     map[string]*sync.Pool
+}
+
+func newCompressor(config Config) *compressor {
+	c := &compressor{config: config, minLength: config.MinLength, pools: make(map[string]*sync.Pool)}
+	if c.minLength <= 0 {
+		c.minLength = 1024
+	}
+	encodings := config.Encodings
+	if len(encodings) == 0 {
+		encodings = []string{"br", "gzip", "deflate"}
+	}
+	gzipLevel := config.GzipLevel
+	if gzipLevel == 0 {
+		gzipLevel = gzip.DefaultCompression
+	}
+	brotliLevel := config.BrotliLevel
+	if brotliLevel == 0 {
+		brotliLevel = 4
+	}
+	for _, encoding := range encodings {
+		encoding = strings.ToLower(encoding)
+		var newEncoder func() encoder
+		switch encoding {
+		case "gzip":
+			if _, err := gzip.NewWriterLevel(io.Discard, gzipLevel); err != nil {
+				panic(err)
+			}
+			newEncoder = func() encoder {
+				w, _ := gzip.NewWriterLevel(io.Discard, gzipLevel)
+				return w
+			}
+		case "deflate":
+			if _, err := zlib.NewWriterLevel(io.Discard, gzipLevel); err != nil {
+				panic(err)
+			}
+			newEncoder = func() encoder {
+				w, _ := zlib.NewWriterLevel(io.Discard, gzipLevel)
+				return w
+			}
+		case "br":
+			newEncoder = func() encoder {
+				return brotli.NewWriterLevel(io.Discard, brotliLevel)
+			}
+		default:
+			panic("compress: unsupported encoding " + encoding)
+		}
+		c.encodings = append(c.encodings, encoding)
+		c.pools[encoding] = &sync.Pool{New: func() any { return newEncoder() }}
+	}
+	for _, t := range config.ContentTypes {
+		c.types = append(c.types, strings.ToLower(t))
+	}
+	if len(c.types) == 0 {
+		c.types = DefaultContentTypes
+	}
+	return c
+}
+
+// encoder gzip.Writer、zlib.Writer 和 brotli.Writer 都实现了这些方法, 可以放回池中复用
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (c *compressor) skipRequest(ctx *fesgo.Context) bool {
+	header := ctx.R.Header
+	// Range 请求的偏移量是针对原始内容的, websocket 需要 Hijack
+	if header.Get("Range") != "" || header.Get("Upgrade") != "" {
+		return true
+	}
+	return c.config.Skip != nil && c.config.Skip(ctx)
+}
+
+// negotiate 按服务端的优先级选择客户端接受的编码, q=0 表示不接受, 没有合适的编码时返回空字符串
+func (c *compressor) negotiate(accept string) string {
+	if accept == "" {
+		return ""
+	}
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = v
+			}
+		}
+		accepted[name] = q
+	}
+	for _, encoding := range c.encodings {
+		q, ok := accepted[encoding]
+		if !ok && encoding == "gzip" {
+			q, ok = accepted["x-gzip"]
+		}
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func (c *compressor) compressible(contentType string) bool {
+	mime, _, _ := strings.Cut(contentType, ";")
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	if mime == "" || mime == "text/event-stream" {
+		return false
+	}
+	for _, t := range c.types {
+		if t == mime {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(t, "*"); ok {
+			if len(mime) >= len(prefix)+len(suffix) && strings.HasPrefix(mime, prefix) && strings.HasSuffix(mime, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
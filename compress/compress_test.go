@@ -0,0 +1,181 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"github.com/dalefeng/fesgo"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var large = strings.Repeat("fesgo compress ", 200)
+
+func newEngine(config Config) *fesgo.Engine {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(config))
+	g.Get("/json", func(ctx *fesgo.Context) {
+		ctx.JSON(http.StatusOK, map[string]string{"data": large})
+	})
+	g.Get("/small", func(ctx *fesgo.Context) {
+		ctx.JSON(http.StatusOK, map[string]string{"data": "ok"})
+	})
+	g.Get("/png", func(ctx *fesgo.Context) {
+		ctx.Data(http.StatusOK, "image/png", []byte(large))
+	})
+	g.Get("/encoded", func(ctx *fesgo.Context) {
+		ctx.W.Header().Set("Content-Encoding", "gzip")
+		ctx.Data(http.StatusOK, "text/plain", []byte(large))
+	})
+	g.Get("/etag", func(ctx *fesgo.Context) {
+		ctx.W.Header().Set("ETag", `"v1"`)
+		ctx.String(http.StatusOK, large)
+	})
+	g.Get("/sse", func(ctx *fesgo.Context) {
+		ctx.SSEvent("message", large)
+		ctx.SSEvent("message", "end")
+	})
+	g.Get("/stream", func(ctx *fesgo.Context) {
+		ctx.W.Header().Set("Content-Type", "text/plain")
+		ctx.W.Write([]byte("part1"))
+		ctx.W.(http.Flusher).Flush()
+		ctx.W.Write([]byte("part2"))
+	})
+	g.Get("/empty", func(ctx *fesgo.Context) {
+		ctx.SetStatusCode(http.StatusNoContent)
+	})
+	return engine
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		r = gr
+	case "deflate":
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		r = zr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestCompress(t *testing.T) {
+	engine := newEngine(Config{})
+	testCase := []struct {
+		name     string
+		path     string
+		accept   string
+		header   map[string]string
+		encoding string
+		vary     bool
+		contains string
+	}{
+		{"gzip", "/api/json", "gzip", nil, "gzip", true, large},
+		{"prefer br", "/api/json", "gzip, deflate, br", nil, "br", true, large},
+		{"deflate", "/api/json", "deflate", nil, "deflate", true, large},
+		{"q zero", "/api/json", "br;q=0, gzip;q=0.5", nil, "gzip", true, large},
+		{"wildcard", "/api/json", "*", nil, "br", true, large},
+		{"no accept", "/api/json", "", nil, "", true, large},
+		{"identity", "/api/json", "identity", nil, "", true, large},
+		{"small", "/api/small", "gzip", nil, "", true, `"ok"`},
+		{"png", "/api/png", "gzip", nil, "", false, large},
+		{"encoded", "/api/encoded", "br", nil, "gzip", false, ""},
+		{"range", "/api/json", "gzip", map[string]string{"Range": "bytes=0-10"}, "", false, large},
+		{"sse", "/api/sse", "gzip", nil, "", false, "data: end"},
+		{"no content", "/api/empty", "gzip", nil, "", false, ""},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept-Encoding", tc.accept)
+			}
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			encoding := w.Header().Get("Content-Encoding")
+			assert.Equal(t, tc.encoding, encoding)
+			if tc.vary {
+				assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			} else {
+				assert.Empty(t, w.Header().Get("Vary"))
+			}
+			if tc.contains != "" {
+				assert.Contains(t, decode(t, encoding, w.Body.Bytes()), tc.contains)
+			}
+		})
+	}
+}
+
+func TestCompressETagAndFlush(t *testing.T) {
+	engine := newEngine(Config{Encodings: []string{"gzip"}})
+	r := httptest.NewRequest(http.MethodGet, "/api/etag", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, `W/"v1"`, w.Header().Get("ETag"))
+
+	// 没有达到 MinLength 时 Flush 也会压缩并发送
+	r = httptest.NewRequest(http.MethodGet, "/api/stream", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "part1part2", decode(t, "gzip", w.Body.Bytes()))
+
+	// 复用池中的 encoder
+	for i := 0; i < 3; i++ {
+		r = httptest.NewRequest(http.MethodGet, "/api/json", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		assert.Contains(t, decode(t, "gzip", w.Body.Bytes()), large)
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	engine := newEngine(Config{})
+	engine.Group("echo").Post("/body", func(ctx *fesgo.Context) {
+		data, err := io.ReadAll(ctx.R.Body)
+		if err != nil {
+			ctx.SetStatusCode(http.StatusBadRequest)
+			return
+		}
+		ctx.W.Write(data)
+	})
+	// 压缩后的响应作为请求 body 发回, 由 Context 按 Content-Encoding 解码
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/json", nil)
+			r.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(t, encoding, w.Header().Get("Content-Encoding"))
+
+			r = httptest.NewRequest(http.MethodPost, "/echo/body", bytes.NewReader(w.Body.Bytes()))
+			r.Header.Set("Content-Encoding", encoding)
+			w = httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), large)
+		})
+	}
+}
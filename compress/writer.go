@@ -0,0 +1,185 @@
+package compress
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// responseWriter 缓冲响应的开头, 达到 MinLength、Flush 或响应结束时根据响应头决定是否压缩
+type responseWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	encoding string // 协商的编码, 为空时不压缩
+	head     bool
+
+	status   int
+	decided  bool
+	hijacked bool
+	buf      []byte
+	enc      encoder
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if code < http.StatusOK {
+		// 1xx 的响应直接发送
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.c.minLength {
+		w.decide(false)
+		if err := w.writeBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide 决定是否压缩并发送响应头, final 表示响应已经结束, 缓冲的内容就是全部内容
+func (w *responseWriter) decide(final bool) {
+	w.decided = true
+	if w.shouldCompress(final) {
+		header := w.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		// 压缩后的内容和原始内容不同, 强校验的 ETag 改为弱校验
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.enc = w.c.pools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *responseWriter) shouldCompress(final bool) bool {
+	header := w.Header()
+	switch {
+	case w.status == http.StatusNoContent, w.status == http.StatusNotModified, w.status == http.StatusPartialContent:
+		return false
+	case header.Get("Content-Encoding") != "", header.Get("Content-Range") != "":
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" && len(w.buf) > 0 {
+		contentType = http.DetectContentType(w.buf)
+		header.Set("Content-Type", contentType)
+	}
+	if !w.c.compressible(contentType) {
+		return false
+	}
+	// 可以压缩的响应都需要 Vary, 避免缓存把压缩的内容返回给不支持的客户端
+	addVary(header, "Accept-Encoding")
+	if w.encoding == "" || w.head {
+		return false
+	}
+	if final && len(w.buf) < w.c.minLength {
+		return false
+	}
+	if length := header.Get("Content-Length"); length != "" {
+		if n, err := strconv.Atoi(length); err == nil && n < w.c.minLength {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *responseWriter) writeBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// Flush 流式响应时发送已经写入的内容, 压缩的内容同样会发送
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(false)
+		w.writeBuffer()
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("compress: response does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close 响应结束, 发送缓冲的内容并把 encoder 放回池中
+func (w *responseWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided && (w.status != 0 || len(w.buf) > 0) {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(true)
+		w.writeBuffer()
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.c.pools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
@@ -0,0 +1,319 @@
+package fesgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/dalefeng/fesgo/render"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Data 响应字节数据, 状态码为 200 时支持 Range 和条件请求
+func (c *Context) Data(status int, contentType string, data []byte) {
+	if status != http.StatusOK {
+		err := c.Render(status, &render.Data{ContentType: contentType, Data: data})
+		if err != nil {
+			c.Abort(err)
+		}
+		return
+	}
+	if contentType != "" {
+		c.W.Header().Set("Content-Type", contentType)
+	}
+	c.serveContent(bytes.NewReader(data), int64(len(data)), time.Time{})
+}
+
+// DataFromReader 从 reader 读取响应内容, extraHeaders 中可以设置 ETag 和 Last-Modified 用于条件请求
+// 状态码为 200 时支持 Range, reader 不支持 Seek 时通过跳过前面的字节实现, 只支持单个范围
+func (c *Context) DataFromReader(status int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	if status != http.StatusOK {
+		err := c.Render(status, &render.Reader{
+			ContentType:   contentType,
+			ContentLength: contentLength,
+			Reader:        reader,
+			Headers:       extraHeaders,
+		})
+		if err != nil {
+			c.Abort(err)
+		}
+		return
+	}
+	header := c.W.Header()
+	for k, v := range extraHeaders {
+		header.Set(k, v)
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	modTime := time.Time{}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		modTime, _ = http.ParseTime(lastModified)
+	}
+	c.serveContent(reader, contentLength, modTime)
+}
+
+// Attachment 以附件形式下载 reader 中的内容, 支持断点续传
+// reader 实现了 io.Seeker、io.ReaderAt 或者能获取到长度时才支持 Range
+func (c *Context) Attachment(reader io.Reader, name string, modTime time.Time) {
+	c.setAttachment(name)
+	header := c.W.Header()
+	if header.Get("Content-Type") == "" {
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+	}
+	size := readerSize(reader)
+	// 没有 ETag 时根据长度和修改时间生成, 用于 If-Range 和 If-None-Match
+	if header.Get("ETag") == "" && size >= 0 && !modTime.IsZero() {
+		header.Set("ETag", fmt.Sprintf(`"%x-%x"`, modTime.Unix(), size))
+	}
+	c.serveContent(reader, size, modTime)
+}
+
+// quoteEscaper 转义 quoted-string 中的 \ 和 "
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func (c *Context) setAttachment(fileName string) {
+	if IsASCII(fileName) {
+		c.W.Header().Set("Content-Disposition", `attachment; filename="`+quoteEscaper.Replace(fileName)+`"`)
+	} else {
+		c.W.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''`+url.PathEscape(fileName))
+	}
+}
+
+// readerSize 获取 reader 剩余的长度, 获取不到时返回 -1
+func readerSize(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case io.Seeker:
+		// 从当前位置计算, reader 可能已经被读取了一部分
+		cur, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err = r.Seek(cur, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - cur
+	case interface{ Len() int }:
+		return int64(r.Len())
+	}
+	return -1
+}
+
+// serveContent 处理条件请求和 Range, 可以 Seek 的 reader 交给 http.ServeContent
+func (c *Context) serveContent(reader io.Reader, size int64, modTime time.Time) {
+	if content := seekableContent(reader, size); content != nil {
+		w := &statusWriter{ResponseWriter: c.W, status: http.StatusOK}
+		http.ServeContent(w, c.R, "", modTime, content)
+		c.StatusCode = w.status
+		return
+	}
+
+	header := c.W.Header()
+	if !modTime.IsZero() && header.Get("Last-Modified") == "" {
+		header.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if c.notModified(modTime) {
+		return
+	}
+
+	start, length := int64(0), size
+	status := http.StatusOK
+	if size >= 0 {
+		header.Set("Accept-Ranges", "bytes")
+		if rangeHeader := c.R.Header.Get("Range"); rangeHeader != "" && c.checkIfRange(modTime) {
+			var err error
+			start, length, err = parseSingleRange(rangeHeader, size)
+			switch {
+			case errors.Is(err, errMultipleRanges):
+				// 不能 Seek 时不支持多个范围, 返回完整内容
+				start, length = 0, size
+			case err != nil:
+				header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+				c.SetStatusCode(http.StatusRequestedRangeNotSatisfiable)
+				return
+			default:
+				status = http.StatusPartialContent
+				header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size))
+			}
+		}
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+	if start > 0 {
+		if _, err := io.CopyN(io.Discard, reader, start); err != nil {
+			c.Abort(err)
+			return
+		}
+	}
+	c.SetStatusCode(status)
+	if c.R.Method == http.MethodHead {
+		return
+	}
+	var err error
+	if length >= 0 {
+		_, err = io.CopyN(c.W, reader, length)
+	} else {
+		_, err = io.Copy(c.W, reader)
+	}
+	// 响应头已经发送, 只能记录日志
+	if err != nil && c.Logger != nil {
+		c.Logger.Error(fmt.Sprintf("serve content: %v", err))
+	}
+}
+
+// seekableContent http.ServeContent 会从头开始读取, reader 不在开头时只返回剩余的部分
+func seekableContent(reader io.Reader, size int64) io.ReadSeeker {
+	if size < 0 {
+		return nil
+	}
+	if rs, ok := reader.(io.ReadSeeker); ok {
+		offset, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil
+		}
+		if offset == 0 {
+			return rs
+		}
+		if ra, ok := reader.(io.ReaderAt); ok {
+			return io.NewSectionReader(ra, offset, size)
+		}
+		return nil
+	}
+	if ra, ok := reader.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, 0, size)
+	}
+	return nil
+}
+
+// notModified If-None-Match 和 If-Modified-Since 命中时返回 304
+func (c *Context) notModified(modTime time.Time) bool {
+	if c.R.Method != http.MethodGet && c.R.Method != http.MethodHead {
+		return false
+	}
+	etag := c.W.Header().Get("ETag")
+	if inm := c.R.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" || !etagMatch(inm, etag, false) {
+			return false
+		}
+	} else {
+		ims := c.R.Header.Get("If-Modified-Since")
+		if ims == "" || modTime.IsZero() {
+			return false
+		}
+		t, err := http.ParseTime(ims)
+		if err != nil || modTime.Truncate(time.Second).After(t) {
+			return false
+		}
+	}
+	header := c.W.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	c.SetStatusCode(http.StatusNotModified)
+	return true
+}
+
+// checkIfRange If-Range 与当前的 ETag 或修改时间一致时 Range 才有效
+func (c *Context) checkIfRange(modTime time.Time) bool {
+	ir := c.R.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+	if strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		etag := c.W.Header().Get("ETag")
+		return etag != "" && etagMatch(ir, etag, true)
+	}
+	t, err := http.ParseTime(ir)
+	return err == nil && !modTime.IsZero() && modTime.Truncate(time.Second).Equal(t)
+}
+
+// etagMatch strong 为 true 时使用强比较, 弱 ETag 不匹配
+func etagMatch(header, etag string, strong bool) bool {
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" && !strong {
+			return true
+		}
+		if strong {
+			if candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+var errMultipleRanges = errors.New("multiple ranges")
+
+// parseSingleRange 解析 Range, 返回起始位置和长度
+func parseSingleRange(s string, size int64) (start, length int64, err error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return 0, 0, errors.New("invalid range")
+	}
+	spec := strings.TrimSpace(s[len(prefix):])
+	if strings.Contains(spec, ",") {
+		return 0, 0, errMultipleRanges
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, errors.New("invalid range")
+	}
+	first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+	if first == "" {
+		// bytes=-n 表示最后 n 个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, errors.New("invalid range")
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, nil
+	}
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, errors.New("invalid range")
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errors.New("invalid range")
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, nil
+}
+
+// statusWriter 记录写入的状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
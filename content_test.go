@@ -0,0 +1,135 @@
+package fesgo
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// onlyReader 隐藏 Seek 和 ReadAt, 模拟对象存储返回的流
+type onlyReader struct {
+	io.Reader
+}
+
+func TestDataFromReader(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	content := "0123456789"
+	testCase := []struct {
+		name         string
+		header       map[string]string
+		wantCode     int
+		want         string
+		contentRange string
+	}{
+		{name: "full", wantCode: http.StatusOK, want: content},
+		{name: "range", header: map[string]string{"Range": "bytes=2-4"}, wantCode: http.StatusPartialContent, want: "234", contentRange: "bytes 2-4/10"},
+		{name: "suffix range", header: map[string]string{"Range": "bytes=-3"}, wantCode: http.StatusPartialContent, want: "789", contentRange: "bytes 7-9/10"},
+		{name: "open range", header: map[string]string{"Range": "bytes=8-"}, wantCode: http.StatusPartialContent, want: "89", contentRange: "bytes 8-9/10"},
+		{name: "multiple ranges", header: map[string]string{"Range": "bytes=0-1,4-5"}, wantCode: http.StatusOK, want: content},
+		{name: "invalid range", header: map[string]string{"Range": "bytes=20-"}, wantCode: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */10"},
+		{name: "if range match", header: map[string]string{"Range": "bytes=0-0", "If-Range": `"v1"`}, wantCode: http.StatusPartialContent, want: "0", contentRange: "bytes 0-0/10"},
+		{name: "if range changed", header: map[string]string{"Range": "bytes=0-0", "If-Range": `"v0"`}, wantCode: http.StatusOK, want: content},
+		{name: "if none match", header: map[string]string{"If-None-Match": `"v0", "v1"`}, wantCode: http.StatusNotModified},
+		{name: "if modified since", header: map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, wantCode: http.StatusNotModified},
+		{name: "modified", header: map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, wantCode: http.StatusOK, want: content},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			ctx := &Context{R: r, W: w}
+			ctx.DataFromReader(http.StatusOK, int64(len(content)), "text/plain", onlyReader{strings.NewReader(content)}, map[string]string{
+				"ETag":          `"v1"`,
+				"Last-Modified": modTime.Format(http.TimeFormat),
+			})
+			assert.Equal(tt, tc.wantCode, w.Code)
+			assert.Equal(tt, tc.wantCode, ctx.StatusCode)
+			assert.Equal(tt, tc.want, w.Body.String())
+			assert.Equal(tt, tc.contentRange, w.Header().Get("Content-Range"))
+		})
+	}
+}
+
+func TestAttachment(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Range", "bytes=6-")
+	w := httptest.NewRecorder()
+	ctx := &Context{R: r, W: w}
+	ctx.Attachment(bytes.NewReader([]byte("hello world")), "报表.csv", modTime)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "world", w.Body.String())
+	assert.Equal(t, "attachment; filename*=UTF-8''%E6%8A%A5%E8%A1%A8.csv", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// 断点续传: If-Range 与 ETag 一致时继续返回部分内容
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Range", "bytes=0-4")
+	r.Header.Set("If-Range", etag)
+	w = httptest.NewRecorder()
+	ctx = &Context{R: r, W: w}
+	ctx.Attachment(bytes.NewReader([]byte("hello world")), "report.csv", modTime)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+	assert.Equal(t, `attachment; filename="report.csv"`, w.Header().Get("Content-Disposition"))
+
+	w = httptest.NewRecorder()
+	ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/", nil), W: w}
+	ctx.Attachment(strings.NewReader("a"), `a\"b.csv`, time.Time{})
+	assert.Equal(t, `attachment; filename="a\\\"b.csv"`, w.Header().Get("Content-Disposition"))
+}
+
+func TestAttachmentPartlyRead(t *testing.T) {
+	// 已经读取了一部分的 reader 只返回剩余的内容
+	reader := strings.NewReader("header,body")
+	io.CopyN(io.Discard, reader, 7)
+	w := httptest.NewRecorder()
+	ctx := &Context{R: httptest.NewRequest(http.MethodGet, "/", nil), W: w}
+	ctx.Attachment(reader, "a.csv", time.Time{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "body", w.Body.String())
+	assert.Equal(t, "4", w.Header().Get("Content-Length"))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Range", "bytes=1-")
+	w = httptest.NewRecorder()
+	ctx = &Context{R: r, W: w}
+	reader = strings.NewReader("header,body")
+	io.CopyN(io.Discard, reader, 7)
+	ctx.Attachment(onlySeeker{reader}, "a.csv", time.Time{})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "ody", w.Body.String())
+}
+
+// onlySeeker 隐藏 ReadAt
+type onlySeeker struct {
+	io.ReadSeeker
+}
+
+func TestData(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := &Context{R: httptest.NewRequest(http.MethodGet, "/", nil), W: w}
+	ctx.Data(http.StatusCreated, "application/octet-stream", []byte("raw"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "raw", w.Body.String())
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+
+	// 没有指定类型时不设置空的 Content-Type, 由 net/http 检测
+	for _, status := range []int{http.StatusOK, http.StatusCreated} {
+		w = httptest.NewRecorder()
+		ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/", nil), W: w}
+		ctx.Data(status, "", []byte("raw"))
+		assert.NotContains(t, w.Header().Values("Content-Type"), "")
+	}
+}
@@ -2,17 +2,18 @@ package fesgo
 
 import (
 	"errors"
-	"fmt"
 	"github.com/dalefeng/fesgo/binding"
 	fesLog "github.com/dalefeng/fesgo/logger"
 	"github.com/dalefeng/fesgo/render"
+	"google.golang.org/protobuf/proto"
 	"html/template"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -35,7 +36,8 @@ type Context struct {
 	Keys map[string]any
 	mu   sync.RWMutex
 
-	sameSite http.SameSite
+	sameSite     http.SameSite
+	maxBodyBytes int64
 }
 
 func (c *Context) SetSameSite(s http.SameSite) {
@@ -58,8 +60,19 @@ func (c *Context) Get(key string) (value any, ok bool) {
 	return
 }
 
+// ClearContext 放回池中之前清理本次请求的数据
 func (c *Context) ClearContext() {
 	c.queryCache = nil
+	c.queryMap = nil
+	c.formCache = nil
+	c.formMap = nil
+	c.StatusCode = 0
+	c.DisallowUnknownFields = false
+	c.sameSite = 0
+	c.maxBodyBytes = 0
+	c.mu.Lock()
+	c.Keys = nil
+	c.mu.Unlock()
 }
 
 func (c *Context) initQueryCache() {
@@ -84,9 +97,9 @@ func (c *Context) initFormCache() {
 		c.formMap = make(map[string]map[string]string)
 		return
 	}
-	if err := c.R.ParseMultipartForm(defaultMultipartMemory); err != nil {
-		if !errors.Is(err, http.ErrNotMultipart) {
-			log.Println(err)
+	if err := c.R.ParseMultipartForm(c.multipartMemory()); err != nil {
+		if !errors.Is(err, http.ErrNotMultipart) && c.Logger != nil {
+			c.Logger.Error(err)
 		}
 	}
 	c.formCache = c.R.PostForm
@@ -132,13 +145,21 @@ func (c *Context) GetPostFormArray(key string) []string {
 	return c.queryCache[key]
 }
 
-func (c *Context) FormFile(name string) *multipart.FileHeader {
+// FormFile 获取上传的文件, 文件大小受 Engine.MaxMultipartMemory 和请求体大小限制
+// 不兼容的修改: 返回值由 *multipart.FileHeader 改为 (*multipart.FileHeader, error),
+// 之前文件不存在时会 panic, 升级后调用方需要处理 error
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if c.R.MultipartForm == nil {
+		if err := c.R.ParseMultipartForm(c.multipartMemory()); err != nil {
+			return nil, err
+		}
+	}
 	file, header, err := c.R.FormFile(name)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
-	defer file.Close()
-	return header
+	file.Close()
+	return header, nil
 }
 
 func (c *Context) FormFiles(name string) []*multipart.FileHeader {
@@ -149,27 +170,62 @@ func (c *Context) FormFiles(name string) []*multipart.FileHeader {
 	return forms.File[name]
 }
 
-func (c *Context) SaveUploadFile(file *multipart.FileHeader, dstPath string) (err error) {
+// SaveUploadFile 保存文件到 dstPath, dstPath 由调用方保证可信, 使用客户端文件名时应使用 SaveUploadFileToDir
+func (c *Context) SaveUploadFile(file *multipart.FileHeader, dstPath string) error {
 	src, err := file.Open()
 	if err != nil {
-		return
+		return err
 	}
 	defer src.Close()
+	if err = os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		return err
+	}
 	dst, err := os.Create(dstPath)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
-	defer dst.Close()
-	_, err = io.Copy(dst, src)
-	return
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// SaveUploadFileToDir 使用清理后的客户端文件名保存到 dir, 返回保存的路径, 文件已存在时返回 os.ErrExist, 不会覆盖
+func (c *Context) SaveUploadFileToDir(file *multipart.FileHeader, dir string) (string, error) {
+	dst, err := SafeJoin(dir, SafeFileName(file.Filename))
+	if err != nil {
+		return "", err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	if err = createFile(dst, src); err != nil {
+		return "", err
+	}
+	return dst, nil
 }
 
 func (c *Context) MultipartForm() (*multipart.Form, error) {
-	err := c.R.ParseMultipartForm(defaultMultipartMemory)
+	err := c.R.ParseMultipartForm(c.multipartMemory())
 	return c.R.MultipartForm, err
 }
 
+func (c *Context) multipartMemory() int64 {
+	if c.engine == nil || c.engine.MaxMultipartMemory <= 0 {
+		return defaultMultipartMemory
+	}
+	return c.engine.MaxMultipartMemory
+}
+
+// Bind 根据 Content-Type 选择绑定器
+func (c *Context) Bind(obj any) error {
+	b := binding.Default(c.ContentType())
+	return c.MustBindWith(obj, b)
+}
+
 func (c *Context) BindJson(obj any) error {
 	json := binding.JSON
 	json.DisallowUnknownFields = false
@@ -179,7 +235,8 @@ func (c *Context) BindJson(obj any) error {
 func (c *Context) MustBindWith(obj any, bind binding.Binding) error {
 	err := c.ShouldBind(obj, bind)
 	if err != nil {
-		c.W.WriteHeader(http.StatusBadRequest)
+		// 请求体过大返回 413, 不支持的压缩格式返回 415
+		c.SetStatusCode(BodyErrorStatus(err))
 		return err
 	}
 	return nil
@@ -196,50 +253,116 @@ func (c *Context) HTML(status int, html string) {
 }
 
 func (c *Context) HTMLTemplate(name string, data any, fileNames ...string) (err error) {
-	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	t := template.New(name)
-	t, err = t.ParseFiles(fileNames...)
+	key := "files:" + name + ":" + strings.Join(fileNames, ",")
+	t, err := c.engine.parseTemplate(key, func(t *template.Template) (*template.Template, error) {
+		return t.ParseFiles(fileNames...)
+	})
 	if err != nil {
+		c.templateError(err)
 		return
 	}
-	err = t.Execute(c.W, data)
+	return c.renderTemplate(http.StatusOK, &render.HTML{Name: name, Data: data, Template: t, IsTemplate: true})
+}
+
+func (c *Context) HTMLTemplateGlob(name string, data any, pattern string) (err error) {
+	t, err := c.engine.parseTemplate("glob:"+pattern, func(t *template.Template) (*template.Template, error) {
+		return t.ParseGlob(pattern)
+	})
 	if err != nil {
-		c.W.Write([]byte(err.Error()))
+		c.templateError(err)
 		return
 	}
-	return
+	return c.renderTemplate(http.StatusOK, &render.HTML{Name: name, Data: data, Template: t, IsTemplate: true})
 }
 
-func (c *Context) HTMLTemplateGlob(name string, data any, pattern string) (err error) {
-	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	t := template.New(name)
-	t, err = t.ParseGlob(pattern)
+// Template 使用 Engine.HTMLRender 渲染
+func (c *Context) Template(name string, data any) {
+	if c.engine.HTMLRender == nil {
+		c.templateError(errors.New("html render is not set"))
+		return
+	}
+	c.renderTemplate(http.StatusOK, c.engine.HTMLRender.Instance(name, data))
+}
+
+func (c *Context) renderTemplate(status int, r render.Render) error {
+	err := c.Render(status, r)
+	if err != nil {
+		c.templateError(err)
+	}
+	return err
+}
+
+// templateError 模板渲染先写入缓冲区, 出错时响应还没有写入, 返回 500 而不是半截的页面
+func (c *Context) templateError(err error) {
+	if c.Logger != nil {
+		c.Logger.Error(err)
+	}
+	c.W.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.Abort(errors.New(http.StatusText(http.StatusInternalServerError)))
+}
+
+func (c *Context) JSON(status int, data any) {
+	err := c.Render(status, &render.Json{Data: data})
 	if err != nil {
+		c.Abort(err)
 		return
 	}
-	err = t.Execute(c.W, data)
+}
+
+func (c *Context) IndentedJSON(status int, data any) {
+	err := c.Render(status, &render.IndentedJSON{Data: data})
 	if err != nil {
-		c.W.Write([]byte(err.Error()))
+		c.Abort(err)
 		return
 	}
-	return
 }
-func (c *Context) Template(name string, data any) {
-	err := c.Render(http.StatusOK, &render.HTML{
-		Name:       name,
-		Data:       data,
-		Template:   c.engine.HTMLRender.Template,
-		IsTemplate: true,
-	})
+
+// SecureJSON 数组响应加上 Engine.SecureJSONPrefix 前缀
+func (c *Context) SecureJSON(status int, data any) {
+	err := c.Render(status, &render.SecureJSON{Prefix: c.engine.SecureJSONPrefix, Data: data})
 	if err != nil {
 		c.Abort(err)
 		return
 	}
-	return
 }
 
-func (c *Context) JSON(status int, data any) {
-	err := c.Render(status, &render.Json{Data: data})
+// JSONP 回调函数名取查询参数 callback, 没有时按 JSON 输出
+func (c *Context) JSONP(status int, data any) {
+	callback := c.GetQuery("callback")
+	if callback == "" {
+		c.JSON(status, data)
+		return
+	}
+	if !render.ValidCallback(callback) {
+		c.String(http.StatusBadRequest, render.ErrInvalidCallback.Error())
+		return
+	}
+	err := c.Render(status, &render.JSONP{Callback: callback, Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+func (c *Context) AsciiJSON(status int, data any) {
+	err := c.Render(status, &render.AsciiJSON{Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+func (c *Context) PureJSON(status int, data any) {
+	err := c.Render(status, &render.PureJSON{Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+// StreamJSON 切片、数组和 chan 逐个元素写入响应, 适合大结果集
+func (c *Context) StreamJSON(status int, data any) {
+	err := c.Render(status, &render.StreamJSON{Data: data})
 	if err != nil {
 		c.Abort(err)
 		return
@@ -254,16 +377,44 @@ func (c *Context) XML(status int, data any) {
 	}
 }
 
+func (c *Context) ProtoBuf(status int, data proto.Message) {
+	err := c.Render(status, &render.ProtoBuf{Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+func (c *Context) MsgPack(status int, data any) {
+	err := c.Render(status, &render.MsgPack{Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+func (c *Context) YAML(status int, data any) {
+	err := c.Render(status, &render.YAML{Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+func (c *Context) TOML(status int, data any) {
+	err := c.Render(status, &render.TOML{Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
 func (c *Context) File(fileName string) {
 	http.ServeFile(c.W, c.R, fileName)
 }
 
 func (c *Context) FileAttachment(filepath string, fileName string) {
-	if IsASCII(fileName) {
-		c.W.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
-	} else {
-		c.W.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''`+url.QueryEscape(fileName))
-	}
+	c.setAttachment(fileName)
 	http.ServeFile(c.W, c.R, filepath)
 }
 
@@ -278,10 +429,24 @@ func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
 
 // Redirect 重定向
 func (c *Context) Redirect(status int, url string) {
-	if (status < http.StatusMultipleChoices || status > http.StatusPermanentRedirect) && status != http.StatusCreated {
-		panic(fmt.Sprintf("cannot redirect with status code %d", status))
+	// 重定向需要在写入状态码之前设置 Location, 不经过 c.Render
+	err := (&render.Redirect{Code: status, Request: c.R, Location: url}).Render(c.W)
+	if err != nil {
+		c.Abort(err)
+		return
 	}
-	http.Redirect(c.W, c.R, url, status)
+	c.StatusCode = status
+}
+
+// RedirectToRoute 重定向到命名路由, 使用 302
+func (c *Context) RedirectToRoute(name string, params map[string]string) error {
+	location, err := c.engine.URL(name, params)
+	if err != nil {
+		c.Abort(err)
+		return err
+	}
+	c.Redirect(http.StatusFound, location)
+	return nil
 }
 
 func (c *Context) String(status int, format string, values ...any) {
@@ -293,6 +458,8 @@ func (c *Context) String(status int, format string, values ...any) {
 }
 
 func (c *Context) Render(statusCode int, r render.Render) error {
+	// 写状态码之后再设置的 Header 不会生效
+	r.WriterContentType(c.W)
 	if statusCode == http.StatusOK {
 		c.StatusCode = http.StatusOK
 	} else {
@@ -322,8 +489,17 @@ func (c *Context) HandleError(statusCode int, obj any, err error) {
 	c.JSON(statusCode, obj)
 }
 
+// ContentType 请求的 Content-Type, 不含参数
+func (c *Context) ContentType() string {
+	contentType := c.R.Header.Get("Content-Type")
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.TrimSpace(contentType)
+}
+
 func (c *Context) SetBase64Auth(username, password string) {
-	c.R.Header.Set("Authorization", "Basic "+BasicAuth(username, password))
+	c.R.Header.Set("Authorization", BasicAuth(username, password))
 }
 
 func (c *Context) SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
@@ -0,0 +1,245 @@
+package fesgo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSecureCookieNotSet = errors.New("cookie: secure cookie keys are not set")
+	ErrCookieSignature    = errors.New("cookie: invalid signature")
+	ErrCookieDecrypt      = errors.New("cookie: decrypt failed")
+	ErrCookieExpired      = errors.New("cookie: expired")
+)
+
+// Cookie 获取 cookie 的值, 使用 SetCookie 写入时会进行 url 编码, 读取时解码
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+// SetCookieData 写入 cookie, 值不做编码, Path 为空时使用 /, SameSite 为空时使用 SetSameSite 的设置
+func (c *Context) SetCookieData(cookie *http.Cookie) {
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = c.sameSite
+	}
+	http.SetCookie(c.W, cookie)
+}
+
+// SetSignedCookie 写入签名的 cookie, 值可以被客户端看到但不能被修改
+func (c *Context) SetSignedCookie(cookie *http.Cookie) error {
+	s, err := c.secureCookie()
+	if err != nil {
+		return err
+	}
+	signed, err := s.Sign(cookie.Name, cookie.Value)
+	if err != nil {
+		return err
+	}
+	cookie.Value = signed
+	c.SetCookieData(cookie)
+	return nil
+}
+
+// SignedCookie 读取签名的 cookie, 签名不正确或过期时返回错误
+func (c *Context) SignedCookie(name string) (string, error) {
+	s, err := c.secureCookie()
+	if err != nil {
+		return "", err
+	}
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return s.Verify(name, cookie.Value)
+}
+
+// SetEncryptedCookie 写入加密的 cookie, 客户端不能查看和修改
+func (c *Context) SetEncryptedCookie(cookie *http.Cookie) error {
+	s, err := c.secureCookie()
+	if err != nil {
+		return err
+	}
+	encrypted, err := s.Encrypt(cookie.Name, cookie.Value)
+	if err != nil {
+		return err
+	}
+	cookie.Value = encrypted
+	c.SetCookieData(cookie)
+	return nil
+}
+
+// EncryptedCookie 读取加密的 cookie
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	s, err := c.secureCookie()
+	if err != nil {
+		return "", err
+	}
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return s.Decrypt(name, cookie.Value)
+}
+
+func (c *Context) secureCookie() (*SecureCookie, error) {
+	if c.engine == nil || c.engine.SecureCookie == nil {
+		return nil, ErrSecureCookieNotSet
+	}
+	return c.engine.SecureCookie, nil
+}
+
+// SecureCookie 签名和加密 cookie
+// 第一个 key 用于签名和加密, 所有 key 都可以用于校验和解密, 轮换时将新 key 放在最前面, 旧 key 在过渡期后删除
+// cookie 名称参与签名和加密, 不同名称的 cookie 值不能互换
+type SecureCookie struct {
+	HashKeys  [][]byte      // HMAC-SHA256 签名使用, 建议 32 字节以上
+	BlockKeys [][]byte      // AES-GCM 加密使用, 长度为 16、24 或 32
+	MaxAge    time.Duration // 值的有效期, 生成时记录时间戳, <= 0 表示不校验
+}
+
+func NewSecureCookie(hashKey, blockKey []byte) *SecureCookie {
+	s := &SecureCookie{HashKeys: [][]byte{hashKey}}
+	if blockKey != nil {
+		s.BlockKeys = [][]byte{blockKey}
+	}
+	return s
+}
+
+// Sign 返回 base64(value).时间戳.base64(签名)
+func (s *SecureCookie) Sign(name, value string) (string, error) {
+	if len(s.HashKeys) == 0 {
+		return "", ErrSecureCookieNotSet
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + strconv.FormatInt(time.Now().Unix(), 10)
+	mac := cookieMAC(s.HashKeys[0], name, payload)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+func (s *SecureCookie) Verify(name, signed string) (string, error) {
+	if len(s.HashKeys) == 0 {
+		return "", ErrSecureCookieNotSet
+	}
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", ErrCookieSignature
+	}
+	payload := signed[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(signed[i+1:])
+	if err != nil {
+		return "", ErrCookieSignature
+	}
+	verified := false
+	for _, key := range s.HashKeys {
+		if hmac.Equal(mac, cookieMAC(key, name, payload)) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return "", ErrCookieSignature
+	}
+	encoded, ts, _ := strings.Cut(payload, ".")
+	if err = s.checkTimestamp(ts); err != nil {
+		return "", err
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrCookieSignature
+	}
+	return string(value), nil
+}
+
+// Encrypt 返回 base64(nonce + 密文), 明文为 时间戳|value, cookie 名称作为附加数据
+func (s *SecureCookie) Encrypt(name, value string) (string, error) {
+	if len(s.BlockKeys) == 0 {
+		return "", ErrSecureCookieNotSet
+	}
+	aead, err := newGCM(s.BlockKeys[0])
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	plaintext := strconv.FormatInt(time.Now().Unix(), 10) + "|" + value
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (s *SecureCookie) Decrypt(name, encrypted string) (string, error) {
+	if len(s.BlockKeys) == 0 {
+		return "", ErrSecureCookieNotSet
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrCookieDecrypt
+	}
+	for _, key := range s.BlockKeys {
+		aead, err := newGCM(key)
+		if err != nil {
+			return "", err
+		}
+		if len(data) < aead.NonceSize() {
+			return "", ErrCookieDecrypt
+		}
+		plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(name))
+		if err != nil {
+			continue
+		}
+		ts, value, ok := strings.Cut(string(plaintext), "|")
+		if !ok {
+			return "", ErrCookieDecrypt
+		}
+		if err = s.checkTimestamp(ts); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	return "", ErrCookieDecrypt
+}
+
+func (s *SecureCookie) checkTimestamp(ts string) error {
+	created, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrCookieSignature
+	}
+	if s.MaxAge > 0 && time.Since(time.Unix(created, 0)) > s.MaxAge {
+		return ErrCookieExpired
+	}
+	return nil
+}
+
+func cookieMAC(key []byte, name, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
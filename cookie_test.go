@@ -0,0 +1,176 @@
+package fesgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testHashKey  = []byte("0123456789abcdef0123456789abcdef")
+	testBlockKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+// cookieRoundTrip 写入 cookie 后带着响应中的 cookie 发起新的请求
+func cookieRoundTrip(engine *Engine, set func(ctx *Context), tamper func(c *http.Cookie)) *Context {
+	w := httptest.NewRecorder()
+	set(&Context{engine: engine, W: w, R: httptest.NewRequest(http.MethodGet, "/", nil)})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		if tamper != nil {
+			tamper(c)
+		}
+		r.AddCookie(c)
+	}
+	return &Context{engine: engine, W: httptest.NewRecorder(), R: r}
+}
+
+func TestCookie(t *testing.T) {
+	ctx := cookieRoundTrip(nil, func(ctx *Context) {
+		ctx.SetCookie("name", "a b&c", 60, "/", "", false, true)
+	}, nil)
+	value, err := ctx.Cookie("name")
+	assert.NoError(t, err)
+	assert.Equal(t, "a b&c", value)
+
+	_, err = ctx.Cookie("missing")
+	assert.ErrorIs(t, err, http.ErrNoCookie)
+
+	w := httptest.NewRecorder()
+	ctx = &Context{W: w}
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookieData(&http.Cookie{Name: "a", Value: "1"})
+	ctx.SetCookieData(&http.Cookie{Name: "b", Value: "2", Path: "/b", SameSite: http.SameSiteStrictMode})
+	cookies := w.Result().Cookies()
+	assert.Equal(t, "/", cookies[0].Path)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	assert.Equal(t, "/b", cookies[1].Path)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[1].SameSite)
+}
+
+func TestSignedCookie(t *testing.T) {
+	oldKey := []byte("old-key-old-key-old-key-old-key!")
+	testCase := []struct {
+		name     string
+		signKeys [][]byte
+		keys     [][]byte
+		maxAge   time.Duration
+		tamper   func(c *http.Cookie)
+		want     string
+		err      error
+	}{
+		{
+			name:     "ok",
+			signKeys: [][]byte{testHashKey},
+			keys:     [][]byte{testHashKey},
+			want:     "uid=1",
+		},
+		{
+			name:     "rotated key",
+			signKeys: [][]byte{oldKey},
+			keys:     [][]byte{testHashKey, oldKey},
+			want:     "uid=1",
+		},
+		{
+			name:     "removed key",
+			signKeys: [][]byte{oldKey},
+			keys:     [][]byte{testHashKey},
+			err:      ErrCookieSignature,
+		},
+		{
+			name:     "tampered",
+			signKeys: [][]byte{testHashKey},
+			keys:     [][]byte{testHashKey},
+			tamper: func(c *http.Cookie) {
+				c.Value = "dWlkPTI" + c.Value[strings.IndexByte(c.Value, '.'):]
+			},
+			err: ErrCookieSignature,
+		},
+		{
+			name:     "renamed",
+			signKeys: [][]byte{testHashKey},
+			keys:     [][]byte{testHashKey},
+			tamper: func(c *http.Cookie) {
+				c.Name = "other"
+			},
+			err: http.ErrNoCookie,
+		},
+		{
+			name:     "tampered timestamp",
+			signKeys: [][]byte{testHashKey},
+			keys:     [][]byte{testHashKey},
+			maxAge:   time.Hour,
+			tamper: func(c *http.Cookie) {
+				parts := strings.Split(c.Value, ".")
+				parts[1] = "1"
+				c.Value = strings.Join(parts, ".")
+			},
+			err: ErrCookieSignature,
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := NewEngine()
+			engine.SecureCookie = &SecureCookie{HashKeys: tc.signKeys}
+			ctx := cookieRoundTrip(engine, func(ctx *Context) {
+				assert.NoError(t, ctx.SetSignedCookie(&http.Cookie{Name: "session", Value: "uid=1"}))
+			}, tc.tamper)
+			engine.SecureCookie = &SecureCookie{HashKeys: tc.keys, MaxAge: tc.maxAge}
+			value, err := ctx.SignedCookie("session")
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, value)
+		})
+	}
+}
+
+func TestSecureCookieMaxAge(t *testing.T) {
+	s := &SecureCookie{HashKeys: [][]byte{testHashKey}, BlockKeys: [][]byte{testBlockKey}}
+	signed, err := s.Sign("a", "1")
+	assert.NoError(t, err)
+	encrypted, err := s.Encrypt("a", "1")
+	assert.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond)
+	s.MaxAge = time.Millisecond
+	_, err = s.Verify("a", signed)
+	assert.ErrorIs(t, err, ErrCookieExpired)
+	_, err = s.Decrypt("a", encrypted)
+	assert.ErrorIs(t, err, ErrCookieExpired)
+}
+
+func TestEncryptedCookie(t *testing.T) {
+	engine := NewEngine()
+	oldKey := []byte("0123456789abcdef")
+	engine.SecureCookie = &SecureCookie{BlockKeys: [][]byte{oldKey}}
+	ctx := cookieRoundTrip(engine, func(ctx *Context) {
+		assert.NoError(t, ctx.SetEncryptedCookie(&http.Cookie{Name: "session", Value: "secret"}))
+	}, func(c *http.Cookie) {
+		assert.NotContains(t, c.Value, "secret")
+	})
+
+	engine.SecureCookie = &SecureCookie{BlockKeys: [][]byte{testBlockKey, oldKey}}
+	value, err := ctx.EncryptedCookie("session")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value)
+
+	engine.SecureCookie = &SecureCookie{BlockKeys: [][]byte{testBlockKey}}
+	_, err = ctx.EncryptedCookie("session")
+	assert.ErrorIs(t, err, ErrCookieDecrypt)
+
+	// 名称参与加密, 不能换成其他 cookie 使用
+	engine.SecureCookie = &SecureCookie{BlockKeys: [][]byte{oldKey}}
+	cookie, _ := ctx.R.Cookie("session")
+	_, err = engine.SecureCookie.Decrypt("other", cookie.Value)
+	assert.ErrorIs(t, err, ErrCookieDecrypt)
+
+	engine.SecureCookie = nil
+	_, err = ctx.EncryptedCookie("session")
+	assert.ErrorIs(t, err, ErrSecureCookieNotSet)
+}
@@ -0,0 +1,157 @@
+package cors
+
+import (
+	"github.com/dalefeng/fesgo"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// AllowOrigins 允许的来源, * 表示所有来源, 支持通配符, 例如 https://*.example.com
+	AllowOrigins    []string
+	AllowOriginFunc func(origin string) bool // 自定义校验, 与 AllowOrigins 任一匹配即可
+	// AllowMethods 默认 GET、POST、PUT、PATCH、DELETE、HEAD
+	AllowMethods []string
+	// AllowHeaders 为空时允许预检请求中的所有请求头
+	AllowHeaders  []string
+	ExposeHeaders []string
+	// AllowCredentials 允许携带凭证, 不能与 AllowOrigins 中的 * 同时使用
+	AllowCredentials bool
+	MaxAge           time.Duration // 预检结果的缓存时间
+}
+
+// Default 允许所有来源
+func Default() fesgo.MiddlewareFunc {
+	return New(Config{AllowOrigins: []string{"*"}})
+}
+
+// New CORS 中间件, 预检请求直接返回 204, 路由只注册了 GET 时也可以处理
+func New(config Config) fesgo.MiddlewareFunc {
+	c := newCors(config)
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			origin := ctx.R.Header.Get("Origin")
+			if origin == "" {
+				next(ctx)
+				return
+			}
+			header := ctx.W.Header()
+			if !c.allowAll {
+				header.Add("Vary", "Origin")
+			}
+			preflight := ctx.R.Method == http.MethodOptions && ctx.R.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				c.handlePreflight(ctx, origin)
+				return
+			}
+			if c.allowOrigin(origin) {
+				c.setOrigin(header, origin)
+				if c.exposeHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+type cors struct {
+	config        Config
+	allowAll      bool
+	origins       []string
+	wildcards     [][2]string
+	methods       map[string]bool
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
+
+func newCors(config Config) *cors {
+	c := &cors{config: config, credentials: config.AllowCredentials, methods: make(map[string]bool)}
+	for _, origin := range config.AllowOrigins {
+		switch {
+		case origin == "*":
+			c.allowAll = true
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(strings.ToLower(origin), "*")
+			c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+		default:
+			c.origins = append(c.origins, strings.ToLower(origin))
+		}
+	}
+	// 任意来源都可以携带凭证读取响应, 等于关闭了同源策略
+	if c.allowAll && c.credentials {
+		panic("cors: AllowCredentials cannot be used with AllowOrigins *")
+	}
+	allowMethods := config.AllowMethods
+	if len(allowMethods) == 0 {
+		allowMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	}
+	methods := make([]string, len(allowMethods))
+	for i, method := range allowMethods {
+		methods[i] = strings.ToUpper(method)
+		c.methods[methods[i]] = true
+	}
+	c.allowMethods = strings.Join(methods, ", ")
+	c.allowHeaders = strings.Join(config.AllowHeaders, ", ")
+	c.exposeHeaders = strings.Join(config.ExposeHeaders, ", ")
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(config.MaxAge / time.Second))
+	}
+	return c
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	for _, o := range c.origins {
+		if o == lower {
+			return true
+		}
+	}
+	for _, w := range c.wildcards {
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	return c.config.AllowOriginFunc != nil && c.config.AllowOriginFunc(origin)
+}
+
+func (c *cors) setOrigin(header http.Header, origin string) {
+	if c.allowAll {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) handlePreflight(ctx *fesgo.Context, origin string) {
+	header := ctx.W.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	method := strings.ToUpper(ctx.R.Header.Get("Access-Control-Request-Method"))
+	if !c.allowOrigin(origin) || !c.methods[method] {
+		ctx.SetStatusCode(http.StatusForbidden)
+		return
+	}
+	c.setOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", c.allowMethods)
+	if c.allowHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", c.allowHeaders)
+	} else if requested := ctx.R.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.maxAge != "" {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	ctx.SetStatusCode(http.StatusNoContent)
+}
@@ -0,0 +1,161 @@
+package cors
+
+import (
+	"github.com/dalefeng/fesgo"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newEngine(config Config) *fesgo.Engine {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(config))
+	g.Get("/users", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "users")
+	})
+	return engine
+}
+
+func TestCORS(t *testing.T) {
+	config := Config{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:3000" },
+		AllowMethods:     []string{"get", "post"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	testCase := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		code    int
+		want    map[string]string
+		body    string
+	}{
+		{
+			name:   "no origin",
+			method: http.MethodGet,
+			code:   http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+			body:   "users",
+		},
+		{
+			name:    "simple",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			code:    http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Total",
+				"Vary":                             "Origin",
+			},
+			body: "users",
+		},
+		{
+			name:    "wildcard",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://a.example.org"},
+			code:    http.StatusOK,
+			want:    map[string]string{"Access-Control-Allow-Origin": "https://a.example.org"},
+		},
+		{
+			name:    "wildcard needs subdomain",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://example.org"},
+			code:    http.StatusOK,
+			want:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "func",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "http://localhost:3000"},
+			code:    http.StatusOK,
+			want:    map[string]string{"Access-Control-Allow-Origin": "http://localhost:3000"},
+		},
+		{
+			name:    "not allowed",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://evil.com"},
+			code:    http.StatusOK,
+			want:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight on get route",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "Content-Type, X-Token",
+			},
+			code: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Content-Type, X-Token",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:   "preflight method not allowed",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			code: http.StatusForbidden,
+			want: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "options without preflight",
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			code:    http.StatusMethodNotAllowed,
+		},
+	}
+	engine := newEngine(config)
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/api/users", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(t, tc.code, w.Code)
+			for k, v := range tc.want {
+				assert.Equal(t, v, w.Header().Get(k), k)
+			}
+			if tc.code == http.StatusNoContent {
+				assert.Empty(t, w.Body.String())
+			}
+			if tc.body != "" {
+				assert.Equal(t, tc.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCORSAllowAll(t *testing.T) {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(Default())
+	g.Get("/users", func(ctx *fesgo.Context) {})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	r.Header.Set("Origin", "https://any.com")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "", w.Header().Get("Vary"))
+}
+
+func TestCORSAllowAllWithCredentials(t *testing.T) {
+	assert.Panics(t, func() {
+		New(Config{AllowOrigins: []string{"*"}, AllowCredentials: true})
+	})
+}
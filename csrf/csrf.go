@@ -0,0 +1,284 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/dalefeng/fesgo"
+	"github.com/dalefeng/fesgo/session"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	secretKey  = "fes_csrf_secret" // 本次请求的密钥在 Context.Keys 中的 key
+	configKey  = "fes_csrf_config"
+	tokenLen   = 32
+	sessionKey = "_csrf"
+)
+
+var (
+	ErrTokenMissing = errors.New("csrf: token missing")
+	ErrTokenInvalid = errors.New("csrf: token invalid")
+	ErrBadOrigin    = errors.New("csrf: origin not allowed")
+	ErrBadReferer   = errors.New("csrf: referer not allowed")
+	ErrNoReferer    = errors.New("csrf: referer missing")
+	ErrNoSession    = errors.New("csrf: session middleware is required")
+)
+
+type Config struct {
+	FieldName  string // 表单字段名, 默认 _csrf
+	HeaderName string // 请求头, 默认 X-CSRF-Token
+	// UseSession 为 true 时密钥保存在会话中 (synchronizer token), session 中间件需要在 csrf 之前执行
+	// 否则保存在 cookie 中 (double-submit), Engine.SecureCookie 不为空时 cookie 会被签名
+	UseSession bool
+
+	CookieName string // 默认 fes_csrf
+	Path       string
+	Domain     string
+	MaxAge     int
+	Secure     bool
+	SameSite   http.SameSite // 默认 Lax
+
+	TrustedOrigins []string // 除了同源之外允许的 Origin, 例如 https://admin.example.com
+	// Exempt 不校验的路径, 以 * 结尾时匹配前缀, 例如 /api/webhook/*
+	Exempt     []string
+	ExemptFunc func(ctx *fesgo.Context) bool
+	// ErrorHandler 校验失败时调用, 默认返回 403
+	ErrorHandler func(ctx *fesgo.Context, err error)
+}
+
+// New CSRF 中间件, 安全方法只生成令牌, 其他方法校验 Origin、Referer 和令牌
+func New(config Config) fesgo.MiddlewareFunc {
+	if config.FieldName == "" {
+		config.FieldName = "_csrf"
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.CookieName == "" {
+		config.CookieName = "fes_csrf"
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = defaultErrorHandler
+	}
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			if config.isExempt(ctx) {
+				next(ctx)
+				return
+			}
+			secret, err := config.secret(ctx)
+			if err != nil {
+				config.ErrorHandler(ctx, err)
+				return
+			}
+			ctx.Set(secretKey, secret)
+			ctx.Set(configKey, &config)
+			if !isSafeMethod(ctx.R.Method) {
+				if err = config.check(ctx, secret); err != nil {
+					config.ErrorHandler(ctx, err)
+					return
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+func defaultErrorHandler(ctx *fesgo.Context, err error) {
+	if errors.Is(err, ErrNoSession) {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.String(http.StatusForbidden, "Forbidden - %s", err.Error())
+}
+
+// Token 获取当前请求的令牌, 每次调用结果不同, 都可以通过校验
+func Token(ctx *fesgo.Context) string {
+	secret, ok := ctx.Get(secretKey)
+	if !ok {
+		return ""
+	}
+	return mask(secret.([]byte))
+}
+
+// TemplateField 生成隐藏的表单字段
+func TemplateField(ctx *fesgo.Context) template.HTML {
+	fieldName := "_csrf"
+	if config, ok := ctx.Get(configKey); ok {
+		fieldName = config.(*Config).FieldName
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(fieldName) +
+		`" value="` + template.HTMLEscapeString(Token(ctx)) + `">`)
+}
+
+// FuncMap 模板函数, 需要合并到 Engine.SetFuncMap 中, 模板中使用 {{ csrfField .ctx }} 或 {{ csrfToken .ctx }}
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"csrfToken": Token,
+		"csrfField": TemplateField,
+	}
+}
+
+func (config *Config) isExempt(ctx *fesgo.Context) bool {
+	if config.ExemptFunc != nil && config.ExemptFunc(ctx) {
+		return true
+	}
+	path := ctx.R.URL.Path
+	for _, exempt := range config.Exempt {
+		if strings.HasSuffix(exempt, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(exempt, "*")) {
+				return true
+			}
+		} else if path == exempt {
+			return true
+		}
+	}
+	return false
+}
+
+// secret 读取密钥, 没有时生成新的密钥
+func (config *Config) secret(ctx *fesgo.Context) ([]byte, error) {
+	if config.UseSession {
+		s := session.Default(ctx)
+		if s == nil {
+			return nil, ErrNoSession
+		}
+		if secret, ok := s.Get(sessionKey).([]byte); ok && len(secret) == tokenLen {
+			return secret, nil
+		}
+		secret := newSecret()
+		s.Set(sessionKey, secret)
+		return secret, nil
+	}
+
+	value, err := ctx.SignedCookie(config.CookieName)
+	if errors.Is(err, fesgo.ErrSecureCookieNotSet) {
+		value, err = ctx.Cookie(config.CookieName)
+	}
+	if err == nil {
+		if secret, err := base64.RawURLEncoding.DecodeString(value); err == nil && len(secret) == tokenLen {
+			return secret, nil
+		}
+	}
+	secret := newSecret()
+	cookie := &http.Cookie{
+		Name:     config.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(secret),
+		Path:     config.Path,
+		Domain:   config.Domain,
+		MaxAge:   config.MaxAge,
+		Secure:   config.Secure,
+		HttpOnly: true,
+		SameSite: config.SameSite,
+	}
+	if err = ctx.SetSignedCookie(cookie); errors.Is(err, fesgo.ErrSecureCookieNotSet) {
+		ctx.SetCookieData(cookie)
+	}
+	return secret, nil
+}
+
+func (config *Config) check(ctx *fesgo.Context, secret []byte) error {
+	if err := config.checkOrigin(ctx); err != nil {
+		return err
+	}
+	token := ctx.R.Header.Get(config.HeaderName)
+	if token == "" {
+		token = ctx.GetPostForm(config.FieldName)
+	}
+	if token == "" {
+		return ErrTokenMissing
+	}
+	if !valid(token, secret) {
+		return ErrTokenInvalid
+	}
+	return nil
+}
+
+// checkOrigin Origin 存在时必须同源或在 TrustedOrigins 中, https 请求没有 Origin 时 Referer 必须同源
+// 经过可信代理时协议和主机名使用代理转发的值, 见 Engine.SetTrustedProxies
+func (config *Config) checkOrigin(ctx *fesgo.Context) error {
+	if origin := ctx.R.Header.Get("Origin"); origin != "" {
+		if !config.sameOrigin(ctx, origin) {
+			return ErrBadOrigin
+		}
+		return nil
+	}
+	referer := ctx.R.Header.Get("Referer")
+	if referer == "" {
+		if ctx.Scheme() == "https" {
+			return ErrNoReferer
+		}
+		return nil
+	}
+	if !config.sameOrigin(ctx, referer) {
+		return ErrBadReferer
+	}
+	return nil
+}
+
+func (config *Config) sameOrigin(ctx *fesgo.Context, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if u.Scheme == ctx.Scheme() && strings.EqualFold(u.Host, ctx.Host()) {
+		return true
+	}
+	for _, trusted := range config.TrustedOrigins {
+		t, err := url.Parse(trusted)
+		if err == nil && t.Scheme == u.Scheme && strings.EqualFold(t.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func newSecret() []byte {
+	b := make([]byte, tokenLen)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// mask 令牌为随机数加上随机数与密钥的异或, 每次响应中的令牌不同, 防止 BREACH 攻击
+func mask(secret []byte) string {
+	otp := newSecret()
+	token := make([]byte, 2*tokenLen)
+	copy(token, otp)
+	for i := 0; i < tokenLen; i++ {
+		token[tokenLen+i] = otp[i] ^ secret[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func valid(token string, secret []byte) bool {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) != 2*tokenLen {
+		return false
+	}
+	unmasked := make([]byte, tokenLen)
+	for i := 0; i < tokenLen; i++ {
+		unmasked[i] = data[i] ^ data[tokenLen+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
@@ -0,0 +1,239 @@
+package csrf
+
+import (
+	"github.com/dalefeng/fesgo"
+	"github.com/dalefeng/fesgo/session"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type request struct {
+	method  string
+	path    string
+	form    url.Values
+	headers map[string]string
+}
+
+// newEngine 注册 /api/form 返回令牌, /api/submit 和 /api/webhook/x 接收提交
+func newEngine(config Config, middles ...fesgo.MiddlewareFunc) *fesgo.Engine {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	// 后添加的中间件先执行, session 需要在 csrf 之前执行
+	g.Use(New(config))
+	g.Use(middles...)
+	g.Get("/form", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, Token(ctx))
+	})
+	g.Post("/submit", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	g.Post("/webhook/x", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	return engine
+}
+
+func do(engine *fesgo.Engine, req request, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	var r *http.Request
+	if req.form != nil {
+		r = httptest.NewRequest(req.method, req.path, strings.NewReader(req.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(req.method, req.path, nil)
+	}
+	for k, v := range req.headers {
+		r.Header.Set(k, v)
+	}
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	return w
+}
+
+func TestCSRF(t *testing.T) {
+	engine := newEngine(Config{Exempt: []string{"/api/webhook/*"}, TrustedOrigins: []string{"https://admin.example.com"}})
+	w := do(engine, request{method: http.MethodGet, path: "/api/form"}, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	token := w.Body.String()
+	assert.NotEmpty(t, token)
+
+	testCase := []struct {
+		name    string
+		req     request
+		cookies []*http.Cookie
+		want    int
+	}{
+		{
+			name:    "form field",
+			req:     request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {token}}},
+			cookies: cookies,
+			want:    http.StatusOK,
+		},
+		{
+			name:    "header",
+			req:     request{method: http.MethodPost, path: "/api/submit", headers: map[string]string{"X-CSRF-Token": token}},
+			cookies: cookies,
+			want:    http.StatusOK,
+		},
+		{
+			name:    "missing token",
+			req:     request{method: http.MethodPost, path: "/api/submit"},
+			cookies: cookies,
+			want:    http.StatusForbidden,
+		},
+		{
+			name: "missing cookie",
+			req:  request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {token}}},
+			want: http.StatusForbidden,
+		},
+		{
+			name:    "invalid token",
+			req:     request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {"abc"}}},
+			cookies: cookies,
+			want:    http.StatusForbidden,
+		},
+		{
+			name: "cross origin",
+			req: request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {token}},
+				headers: map[string]string{"Origin": "http://evil.com"}},
+			cookies: cookies,
+			want:    http.StatusForbidden,
+		},
+		{
+			name: "trusted origin",
+			req: request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {token}},
+				headers: map[string]string{"Origin": "https://admin.example.com"}},
+			cookies: cookies,
+			want:    http.StatusOK,
+		},
+		{
+			name: "cross referer",
+			req: request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {token}},
+				headers: map[string]string{"Referer": "http://evil.com/page"}},
+			cookies: cookies,
+			want:    http.StatusForbidden,
+		},
+		{
+			name: "same referer",
+			req: request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {token}},
+				headers: map[string]string{"Referer": "http://example.com/api/form"}},
+			cookies: cookies,
+			want:    http.StatusOK,
+		},
+		{
+			name: "exempt",
+			req:  request{method: http.MethodPost, path: "/api/webhook/x"},
+			want: http.StatusOK,
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			w := do(engine, tc.req, tc.cookies)
+			assert.Equal(t, tc.want, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestCSRFBehindProxy(t *testing.T) {
+	engine := newEngine(Config{})
+	// httptest 请求的对端地址是 192.0.2.1
+	assert.NoError(t, engine.SetTrustedProxies([]string{"192.0.2.1"}))
+	w := do(engine, request{method: http.MethodGet, path: "/api/form"}, nil)
+	cookies := w.Result().Cookies()
+	token := w.Body.String()
+	proxy := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "www.example.com"}
+	with := func(headers map[string]string) map[string]string {
+		merged := map[string]string{}
+		for k, v := range proxy {
+			merged[k] = v
+		}
+		for k, v := range headers {
+			merged[k] = v
+		}
+		return merged
+	}
+
+	testCase := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "origin", headers: with(map[string]string{"Origin": "https://www.example.com"}), want: http.StatusOK},
+		{name: "referer", headers: with(map[string]string{"Referer": "https://www.example.com/form"}), want: http.StatusOK},
+		{name: "http origin", headers: with(map[string]string{"Origin": "http://www.example.com"}), want: http.StatusForbidden},
+		{name: "no referer", headers: proxy, want: http.StatusForbidden},
+		{name: "forwarded", headers: map[string]string{"Forwarded": `proto=https;host="www.example.com"`, "Origin": "https://www.example.com"}, want: http.StatusOK},
+		{name: "spoofed host", headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com, www.example.com", "Origin": "https://evil.com"}, want: http.StatusForbidden},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			req := request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {token}}, headers: tc.headers}
+			w := do(engine, req, cookies)
+			assert.Equal(t, tc.want, w.Code, w.Body.String())
+		})
+	}
+
+	// 不可信的对端转发的请求头被忽略
+	engine = newEngine(Config{})
+	w = do(engine, request{method: http.MethodGet, path: "/api/form"}, nil)
+	req := request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {w.Body.String()}},
+		headers: with(map[string]string{"Origin": "https://www.example.com"})}
+	assert.Equal(t, http.StatusForbidden, do(engine, req, w.Result().Cookies()).Code)
+}
+
+func TestCSRFTokenMasked(t *testing.T) {
+	secret := newSecret()
+	a, b := mask(secret), mask(secret)
+	assert.NotEqual(t, a, b)
+	assert.True(t, valid(a, secret))
+	assert.True(t, valid(b, secret))
+	assert.False(t, valid(a, newSecret()))
+}
+
+func TestCSRFSession(t *testing.T) {
+	errs := make([]error, 0)
+	config := Config{
+		UseSession: true,
+		ErrorHandler: func(ctx *fesgo.Context, err error) {
+			errs = append(errs, err)
+			ctx.SetStatusCode(http.StatusTeapot)
+		},
+	}
+	engine := newEngine(config, session.New(session.NewMemoryStore(), session.Options{}).Sessions)
+	w := do(engine, request{method: http.MethodGet, path: "/api/form"}, nil)
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "fes_session", cookies[0].Name)
+
+	w = do(engine, request{method: http.MethodPost, path: "/api/submit", form: url.Values{"_csrf": {w.Body.String()}}}, cookies)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do(engine, request{method: http.MethodPost, path: "/api/submit"}, cookies)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, []error{ErrTokenMissing}, errs)
+
+	// 没有使用 session 中间件
+	do(newEngine(config), request{method: http.MethodGet, path: "/api/form"}, nil)
+	assert.Equal(t, ErrNoSession, errs[1])
+}
+
+func TestTemplateField(t *testing.T) {
+	assert.Equal(t, "", Token(&fesgo.Context{}))
+
+	var field string
+	engine := fesgo.NewEngine()
+	g := engine.Group("tpl")
+	g.Use(New(Config{FieldName: "token"}))
+	g.Get("/", func(ctx *fesgo.Context) {
+		field = string(TemplateField(ctx))
+	})
+	do(engine, request{method: http.MethodGet, path: "/tpl/"}, nil)
+	assert.True(t, strings.HasPrefix(field, `<input type="hidden" name="token" value="`), field)
+}
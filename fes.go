@@ -4,9 +4,14 @@ import (
 	"fmt"
 	fesLog "github.com/dalefeng/fesgo/logger"
 	"github.com/dalefeng/fesgo/render"
+	"github.com/dalefeng/fesgo/websocket"
 	"html/template"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 )
 
@@ -16,15 +21,39 @@ type Engine struct {
 	router
 	funcMap      template.FuncMap
 	HTMLRender   render.HTMLRender
+	tplCache     sync.Map // HTMLTemplate 和 HTMLTemplateGlob 编译后的模板
 	pool         sync.Pool
 	Logger       *fesLog.Logger
 	middles      []MiddlewareFunc
 	errorHandler ErrorHandler
+
+	MaxBodyBytes       int64 // 请求体大小限制, <= 0 表示不限制
+	MaxMultipartMemory int64 // multipart 表单解析时使用的内存上限
+	SecureJSONPrefix   string
+	WebSocketUpgrader  *websocket.Upgrader // 为空时使用默认配置
+	SecureCookie       *SecureCookie       // 签名和加密 cookie 使用的 key
+
+	RedirectTrailingSlash bool // 路由不存在但去掉或加上末尾的 / 后存在时重定向
+	RedirectFixedPath     bool // 清理 ..、重复的 / 并忽略大小写后存在路由时重定向
+	RemoveExtraSlash      bool // 匹配路由前清理路径, 不重定向
+
+	GroupMiddlewareFirst bool // 组中间件先于路由级中间件执行, 默认路由级中间件先执行
+
+	namedRoutes map[string]*Route
+
+	trustedCIDRs    []*net.IPNet
+	RemoteIPHeaders []string // 来自可信代理时获取客户端 IP 的请求头, 按顺序查找
 }
 
 func NewEngine() *Engine {
 	engine := &Engine{
-		router: router{},
+		router:             router{},
+		MaxMultipartMemory: defaultMultipartMemory,
+		SecureJSONPrefix:   "while(1);",
+
+		RedirectTrailingSlash: true,
+		namedRoutes:           make(map[string]*Route),
+		RemoteIPHeaders:       []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"},
 	}
 	engine.router.Engine = engine
 	engine.pool.New = func() any {
@@ -55,7 +84,45 @@ func (e *Engine) LoadTemplate(pattern string) {
 }
 
 func (e *Engine) SetHtmlTemplate(t *template.Template) {
-	e.HTMLRender = render.HTMLRender{Template: t}
+	e.HTMLRender = render.HTMLProduction{Template: t}
+}
+
+// LoadTemplateDirs 从多个目录加载模板, debug 模式下文件变化时自动重新编译
+func (e *Engine) LoadTemplateDirs(dirs ...string) error {
+	return e.SetTemplateManager(render.NewTemplateManager(e.funcMap).AddDir(dirs...))
+}
+
+// LoadTemplateFS 从 fs.FS 加载模板, 例如 embed.FS
+func (e *Engine) LoadTemplateFS(fsys ...fs.FS) error {
+	return e.SetTemplateManager(render.NewTemplateManager(e.funcMap).AddFS(fsys...))
+}
+
+func (e *Engine) SetTemplateManager(m *render.TemplateManager) error {
+	if IsDebugging() {
+		m.AutoReload = true
+	}
+	if err := m.Load(); err != nil {
+		return err
+	}
+	e.HTMLRender = m
+	return nil
+}
+
+// parseTemplate 编译 HTMLTemplate 和 HTMLTemplateGlob 使用的模板, 非 debug 模式下缓存编译结果
+func (e *Engine) parseTemplate(key string, parse func(t *template.Template) (*template.Template, error)) (*template.Template, error) {
+	if !IsDebugging() {
+		if t, ok := e.tplCache.Load(key); ok {
+			return t.(*template.Template), nil
+		}
+	}
+	t, err := parse(template.New("").Funcs(e.funcMap))
+	if err != nil {
+		return nil, err
+	}
+	if !IsDebugging() {
+		e.tplCache.Store(key, t)
+	}
+	return t, nil
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -63,6 +130,10 @@ func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx.W = w
 	ctx.R = r
 	ctx.Logger = e.Logger
+	ctx.maxBodyBytes = e.MaxBodyBytes
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = newRequestBody(ctx, r)
+	}
 	e.httpRequestHandle(ctx, w, r)
 	ctx.ClearContext()
 	e.pool.Put(ctx)
@@ -70,41 +141,195 @@ func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (e *Engine) httpRequestHandle(ctx *Context, w http.ResponseWriter, r *http.Request) {
 	method := r.Method
-	for _, group := range e.routerGroups {
-		// 将分组截取
-		routerName := SubStringLast(r.URL.Path, "/"+group.name)
-		node := group.treeNode.Get(routerName)
-		if node == nil || !node.isEnd {
-			// 路由没匹配
-			ctx.StatusCode = http.StatusNotFound
-			group.MethodHandle(ctx, routerName, ANY, nil)
-			w.WriteHeader(http.StatusNotFound)
-			fmt.Fprintf(w, "%s %s not found - tree node", r.RequestURI, method)
+	path := r.URL.Path
+	if e.RemoveExtraSlash {
+		path = CleanPath(path)
+	}
+	group, node, routerName := e.findRoute(path)
+	if node == nil {
+		if method != http.MethodConnect && path != "/" && e.redirectPath(ctx, path) {
 			return
 		}
-		// 优先匹配 Any
-		handleFunc, ok := group.handleFuncMap[node.routerName][ANY]
-		if ok {
-			group.MethodHandle(ctx, node.routerName, ANY, handleFunc)
+		if group == nil {
+			// 路由匹配失败
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "%s %s not found", r.RequestURI, method)
 			return
 		}
-
-		// method 匹配
-		handleFunc, ok = group.handleFuncMap[node.routerName][method]
-		if ok {
-			group.MethodHandle(ctx, node.routerName, method, handleFunc)
+		// 路由没匹配
+		ctx.StatusCode = http.StatusNotFound
+		group.MethodHandle(ctx, routerName, ANY, nil)
+		if ctx.StatusCode != http.StatusNotFound {
+			// 中间件已经处理了请求
 			return
 		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "%s %s not found - tree node", r.RequestURI, method)
+		return
+	}
+	// 优先匹配 Any
+	handleFunc, ok := group.handleFuncMap[node.routerName][ANY]
+	if ok {
+		group.MethodHandle(ctx, node.routerName, ANY, handleFunc)
+		return
+	}
 
-		ctx.StatusCode = http.StatusMethodNotAllowed
-		group.MethodHandle(ctx, node.routerName, ANY, nil)
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		fmt.Fprintf(w, "%s %s not allowed", r.RequestURI, method)
+	// method 匹配
+	handleFunc, ok = group.handleFuncMap[node.routerName][method]
+	if ok {
+		group.MethodHandle(ctx, node.routerName, method, handleFunc)
 		return
 	}
-	// 路由匹配失败
-	w.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(w, "%s %s not found", r.RequestURI, method)
+
+	ctx.StatusCode = http.StatusMethodNotAllowed
+	group.MethodHandle(ctx, node.routerName, ANY, nil)
+	if ctx.StatusCode != http.StatusMethodNotAllowed {
+		// 中间件已经处理了请求, 例如只注册了 GET 的路由上的 CORS 预检请求
+		return
+	}
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	fmt.Fprintf(w, "%s %s not allowed", r.RequestURI, method)
+}
+
+// findRoute 查找路径对应的分组和路由节点, 没有匹配的路由时 group 为前缀匹配的分组
+func (e *Engine) findRoute(path string) (*routerGroup, *treeNode, string) {
+	var prefixGroup *routerGroup
+	prefixRouterName := ""
+	for _, group := range e.routerGroups {
+		prefix := "/" + group.name
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		// 将分组截取
+		routerName := path[len(prefix):]
+		if routerName != "" && routerName[0] != '/' {
+			continue
+		}
+		node := group.treeNode.Get(routerName)
+		if node != nil && node.isEnd {
+			return group, node, routerName
+		}
+		if prefixGroup == nil {
+			prefixGroup, prefixRouterName = group, routerName
+		}
+	}
+	if prefixGroup == nil && len(e.routerGroups) > 0 {
+		prefixGroup = e.routerGroups[0]
+		prefixRouterName = path
+	}
+	return prefixGroup, nil, prefixRouterName
+}
+
+// redirectPath 路由没有匹配时尝试修正路径并重定向, GET 使用 301, 其他方法使用 308 保留请求方法和请求体
+func (e *Engine) redirectPath(ctx *Context, path string) bool {
+	candidates := make([]string, 0, 2)
+	if e.RedirectTrailingSlash {
+		if strings.HasSuffix(path, "/") {
+			candidates = append(candidates, strings.TrimSuffix(path, "/"))
+		} else {
+			candidates = append(candidates, path+"/")
+		}
+	}
+	if e.RedirectFixedPath {
+		cleaned := CleanPath(path)
+		candidates = append(candidates, cleaned)
+		if e.RedirectTrailingSlash {
+			if strings.HasSuffix(cleaned, "/") {
+				candidates = append(candidates, strings.TrimSuffix(cleaned, "/"))
+			} else {
+				candidates = append(candidates, cleaned+"/")
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		target := ""
+		if candidate != path {
+			if _, node, _ := e.findRoute(candidate); node != nil {
+				target = candidate
+			}
+		}
+		if target == "" && e.RedirectFixedPath {
+			target = e.findCaseInsensitive(candidate)
+		}
+		if target == "" || target == path {
+			continue
+		}
+		// 以 // 或 /\ 开头的地址会被浏览器当作其他域名, 合并为一个 /
+		target = "/" + strings.TrimLeft(target, "/\\")
+		if target == path {
+			continue
+		}
+		code := http.StatusMovedPermanently
+		if ctx.R.Method != http.MethodGet {
+			code = http.StatusPermanentRedirect
+		}
+		if ctx.R.URL.RawQuery != "" {
+			target += "?" + ctx.R.URL.RawQuery
+		}
+		ctx.StatusCode = code
+		http.Redirect(ctx.W, ctx.R, target, code)
+		return true
+	}
+	return false
+}
+
+// findCaseInsensitive 忽略大小写查找路由, 返回注册时的路径写法
+func (e *Engine) findCaseInsensitive(path string) string {
+	for _, group := range e.routerGroups {
+		prefix := "/" + group.name
+		if len(path) <= len(prefix) || !strings.EqualFold(path[:len(prefix)], prefix) || path[len(prefix)] != '/' {
+			continue
+		}
+		if fixed, ok := group.treeNode.findCaseInsensitive(path[len(prefix):]); ok {
+			return prefix + fixed
+		}
+	}
+	return ""
+}
+
+// URL 根据路由名称和参数生成路径, 路径中没有用到的参数作为查询参数
+func (e *Engine) URL(name string, params map[string]string) (string, error) {
+	route, ok := e.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("route %q not found", name)
+	}
+	used := make(map[string]bool)
+	segments := strings.Split(route.Path, "/")
+	for i, segment := range segments {
+		key := ""
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			key = segment[1:]
+		case segment == "*" || segment == "**":
+			key = segment
+		default:
+			continue
+		}
+		value, ok := params[key]
+		if !ok {
+			return "", fmt.Errorf("route %q missing param %q", name, key)
+		}
+		used[key] = true
+		if segment == "**" {
+			segments[i] = strings.TrimPrefix(value, "/")
+		} else {
+			segments[i] = url.PathEscape(value)
+		}
+	}
+	query := url.Values{}
+	for k, v := range params {
+		if !used[k] {
+			query.Set(k, v)
+		}
+	}
+	u := strings.Join(segments, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u, nil
 }
 
 func (e *Engine) Run(addr string) {
@@ -0,0 +1,297 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dalefeng/fesgo"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyMissing  = errors.New("idempotency: key missing")
+	ErrKeyInvalid  = errors.New("idempotency: key too long")
+	ErrInProgress  = errors.New("idempotency: request with the same key is in progress")
+	ErrKeyReused   = errors.New("idempotency: key reused with a different request")
+	ErrBodyTooLong = errors.New("idempotency: request body too large")
+)
+
+const (
+	maxKeyLen  = 255
+	minLockTTL = 10 * time.Millisecond // 延长记录的间隔是 LockTTL/2, 不能太短
+)
+
+type Config struct {
+	Store  Store  // 默认 NewMemoryStore()
+	Header string // 默认 Idempotency-Key
+	// Methods 需要处理的方法, 默认 POST 和 PATCH
+	Methods []string
+	// Required 没有 Idempotency-Key 时拒绝请求, 默认直接执行
+	Required bool
+	TTL      time.Duration // 响应保存的时间, 默认 24 小时
+	// LockTTL 处理中的记录保存的时间, 避免进程退出后 key 一直处于处理中, 默认 1 分钟
+	// 处理时间超过 LockTTL 时每隔 LockTTL/2 延长一次, 不能小于 10ms
+	LockTTL time.Duration
+	// ScopeFunc 区分不同用户的 key, 默认使用 Context 中的 user, 由认证中间件设置,
+	// 中间件需要在认证之后执行, 否则所有用户共用同一个范围; 用户信息保存在其他位置时需要设置
+	ScopeFunc func(ctx *fesgo.Context) string
+	// MaxBodySize 计算请求指纹时读取 body 的上限, 默认 10MB, 同时受 Engine.MaxBodyBytes 限制
+	MaxBodySize int64
+	// ErrorHandler 默认没有 key 返回 400, 处理中返回 409, key 被不同的请求使用返回 422,
+	// 读取 body 出错时按 fesgo.BodyErrorStatus 返回, 存储出错返回 500
+	ErrorHandler func(ctx *fesgo.Context, err error)
+}
+
+// New Idempotency-Key 中间件, 第一个请求的响应会被保存, 使用同一个 key 重试时返回保存的响应,
+// 5xx 的响应不保存, 客户端可以重试
+func New(config Config) fesgo.MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.Header == "" {
+		config.Header = "Idempotency-Key"
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = time.Minute
+	}
+	if config.LockTTL < minLockTTL {
+		panic("idempotency: LockTTL must be at least 10ms")
+	}
+	if config.ScopeFunc == nil {
+		config.ScopeFunc = defaultScope
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 10 << 20
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = defaultErrorHandler
+	}
+	methods := make(map[string]bool)
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			if !methods[ctx.R.Method] {
+				next(ctx)
+				return
+			}
+			key := ctx.R.Header.Get(config.Header)
+			if key == "" {
+				if config.Required {
+					config.ErrorHandler(ctx, ErrKeyMissing)
+					return
+				}
+				next(ctx)
+				return
+			}
+			if len(key) > maxKeyLen {
+				config.ErrorHandler(ctx, ErrKeyInvalid)
+				return
+			}
+			fingerprint, err := fingerprint(ctx, config.MaxBodySize)
+			if err != nil {
+				config.ErrorHandler(ctx, err)
+				return
+			}
+			key = config.ScopeFunc(ctx) + ":" + key
+			record, ok, err := config.Store.Lock(key, fingerprint, config.LockTTL)
+			if err != nil {
+				config.ErrorHandler(ctx, err)
+				return
+			}
+			if !ok {
+				switch {
+				case record.Fingerprint != fingerprint:
+					config.ErrorHandler(ctx, ErrKeyReused)
+				case record.Status == 0:
+					config.ErrorHandler(ctx, ErrInProgress)
+				default:
+					replay(ctx, record)
+				}
+				return
+			}
+			execute(ctx, config, key, fingerprint, next)
+		}
+	}
+}
+
+// execute 执行第一个请求并保存响应, 没有保存时删除记录
+func execute(ctx *fesgo.Context, config Config, key, fingerprint string, next fesgo.HandlerFunc) {
+	w := &recorder{ResponseWriter: ctx.W}
+	ctx.W = w
+	saved := false
+	stop := refreshLock(ctx, config, key)
+	defer func() {
+		stop()
+		ctx.W = w.ResponseWriter
+		if !saved {
+			config.Store.Delete(key)
+		}
+	}()
+	next(ctx)
+	stop()
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status >= http.StatusInternalServerError {
+		return
+	}
+	record := &Record{Fingerprint: fingerprint, Status: status, Header: w.header, Body: w.body.Bytes()}
+	if record.Header == nil {
+		record.Header = w.Header().Clone()
+	}
+	if err := config.Store.Save(key, record, config.TTL); err != nil {
+		if ctx.Logger != nil {
+			ctx.Logger.Error(fmt.Sprintf("idempotency: %v", err))
+		}
+		return
+	}
+	saved = true
+}
+
+// refreshLock 处理时间超过 LockTTL 时延长处理中的记录, 避免重试的请求再次执行, 返回的 stop 可以多次调用
+func refreshLock(ctx *fesgo.Context, config Config, key string) (stop func()) {
+	done, exited := make(chan struct{}), make(chan struct{})
+	logger := ctx.Logger
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(config.LockTTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := config.Store.Refresh(key, config.LockTTL); err != nil && logger != nil {
+					logger.Error(fmt.Sprintf("idempotency: %v", err))
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-exited
+		})
+	}
+}
+
+func replay(ctx *fesgo.Context, record *Record) {
+	header := ctx.W.Header()
+	for k, v := range record.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("Idempotent-Replayed", "true")
+	ctx.SetStatusCode(record.Status)
+	ctx.W.Write(record.Body)
+}
+
+// bodyError 读取请求体出错, 状态码由 fesgo.BodyErrorStatus 决定
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string {
+	return "idempotency: read body: " + e.err.Error()
+}
+
+func (e *bodyError) Unwrap() error {
+	return e.err
+}
+
+// fingerprint 请求方法、路径和 body 的哈希, 读取 body 后通过 ResetBody 放回请求中,
+// 之后的读取仍然受 Context 当前的限制, 例如路由级的 fesgo.BodyLimit
+func fingerprint(ctx *fesgo.Context, limit int64) (string, error) {
+	r := ctx.R
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			return "", &bodyError{err: err}
+		}
+		if int64(len(body)) > limit {
+			return "", ErrBodyTooLong
+		}
+		r.Body.Close()
+		ctx.ResetBody(body)
+	}
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// defaultScope 使用认证中间件设置的 user, 没有时所有请求共用同一个范围
+func defaultScope(ctx *fesgo.Context) string {
+	if user, ok := ctx.Get("user"); ok {
+		return fmt.Sprint(user)
+	}
+	return ""
+}
+
+func defaultErrorHandler(ctx *fesgo.Context, err error) {
+	status := http.StatusInternalServerError
+	var bodyErr *bodyError
+	switch {
+	case errors.As(err, &bodyErr):
+		status = fesgo.BodyErrorStatus(bodyErr.err)
+	case errors.Is(err, ErrKeyMissing), errors.Is(err, ErrKeyInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrInProgress):
+		status = http.StatusConflict
+	case errors.Is(err, ErrKeyReused):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, ErrBodyTooLong):
+		status = http.StatusRequestEntityTooLarge
+	}
+	ctx.String(status, http.StatusText(status))
+}
+
+// recorder 响应正常发送, 同时记录状态码、响应头和内容
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,176 @@
+package idempotency
+
+import (
+	"github.com/dalefeng/fesgo"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	var orders int32
+	started, release := make(chan struct{}), make(chan struct{})
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{}))
+	g.Post("/orders", func(ctx *fesgo.Context) {
+		body, _ := io.ReadAll(ctx.R.Body)
+		if string(body) == "slow" {
+			close(started)
+			<-release
+		}
+		if string(body) == "fail" {
+			ctx.String(http.StatusInternalServerError, "fail")
+			return
+		}
+		n := atomic.AddInt32(&orders, 1)
+		ctx.W.Header().Set("X-Order", "created")
+		ctx.String(http.StatusCreated, "order %d %s", n, body)
+	})
+
+	do := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("k1", "apple")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "order 1 apple", w.Body.String())
+
+	// 重试返回同样的响应, 不再执行 handler
+	w = do("k1", "apple")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "order 1 apple", w.Body.String())
+	assert.Equal(t, "created", w.Header().Get("X-Order"))
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, do("k1", "banana").Code)
+	assert.Equal(t, "order 2 apple", do("k2", "apple").Body.String())
+	// 没有 key 时直接执行
+	assert.Equal(t, "order 3 apple", do("", "apple").Body.String())
+	assert.Equal(t, http.StatusBadRequest, do(strings.Repeat("k", 256), "apple").Code)
+
+	// 5xx 不保存, 可以重试
+	assert.Equal(t, http.StatusInternalServerError, do("k3", "fail").Code)
+	assert.Equal(t, http.StatusInternalServerError, do("k3", "fail").Code)
+
+	// 处理中的重复请求
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- do("k4", "slow")
+	}()
+	<-started
+	assert.Equal(t, http.StatusConflict, do("k4", "slow").Code)
+	close(release)
+	assert.Equal(t, "order 4 slow", (<-done).Body.String())
+	assert.Equal(t, "order 4 slow", do("k4", "slow").Body.String())
+	assert.Equal(t, int32(4), atomic.LoadInt32(&orders))
+}
+
+func TestRequiredAndScope(t *testing.T) {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{Required: true}))
+	g.Use(func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			ctx.Set("user", ctx.R.Header.Get("X-User"))
+			next(ctx)
+		}
+	})
+	g.Post("/orders", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "%s", ctx.R.Header.Get("X-User"))
+	})
+	g.Get("/orders", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "list")
+	})
+
+	do := func(method, key, user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/orders", nil)
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		r.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "", "a").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "", "a").Code)
+	// 不同用户使用同一个 key 互不影响
+	assert.Equal(t, "a", do(http.MethodPost, "k1", "a").Body.String())
+	assert.Equal(t, "b", do(http.MethodPost, "k1", "b").Body.String())
+}
+
+func TestBodyLimit(t *testing.T) {
+	engine := fesgo.NewEngine()
+	engine.MaxBodyBytes = 1 << 10
+	g := engine.Group("api")
+	g.Use(New(Config{}))
+	handler := func(ctx *fesgo.Context) {
+		body, err := io.ReadAll(ctx.R.Body)
+		if err != nil {
+			ctx.String(fesgo.BodyErrorStatus(err), err.Error())
+			return
+		}
+		ctx.String(http.StatusOK, "%d", len(body))
+	}
+	g.Post("/small", handler, fesgo.BodyLimit(10))
+	g.Post("/large", handler)
+
+	do := func(path, key string, size int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(strings.Repeat("a", size)))
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	// 路由级的限制在读取放回的 body 时生效
+	assert.Equal(t, http.StatusRequestEntityTooLarge, do("/api/small", "k1", 5000).Code)
+	assert.Equal(t, "5", do("/api/small", "k2", 5).Body.String())
+	// 超过 Engine.MaxBodyBytes 时返回 413 而不是 500
+	assert.Equal(t, http.StatusRequestEntityTooLarge, do("/api/large", "k3", 5000).Code)
+	assert.Equal(t, "1000", do("/api/large", "k4", 1000).Body.String())
+}
+
+func TestLockRefresh(t *testing.T) {
+	var orders int32
+	started, release := make(chan struct{}), make(chan struct{})
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{LockTTL: 20 * time.Millisecond}))
+	g.Post("/orders", func(ctx *fesgo.Context) {
+		close(started)
+		<-release
+		ctx.String(http.StatusCreated, "order %d", atomic.AddInt32(&orders, 1))
+	})
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/orders", nil)
+		r.Header.Set("Idempotency-Key", "k1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- do()
+	}()
+	<-started
+	// 处理时间超过 LockTTL, 记录被延长, 重试仍然返回 409
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusConflict, do().Code)
+	close(release)
+	assert.Equal(t, "order 1", (<-done).Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&orders))
+
+	assert.Panics(t, func() { New(Config{LockTTL: time.Nanosecond}) })
+}
@@ -0,0 +1,111 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"github.com/dalefeng/fesgo/orm"
+	"net/http"
+	"time"
+)
+
+// idempotencyRow 表结构, 以 mysql 为例:
+//
+//	create table idempotency_keys (
+//		id          varchar(255) not null primary key,
+//		fingerprint varchar(64)  not null,
+//		status      int          not null,
+//		header      blob         not null,
+//		body        mediumblob   not null,
+//		expiry      bigint       not null,
+//		index idx_expiry (expiry)
+//	)
+type idempotencyRow struct {
+	Id          string
+	Fingerprint string
+	Status      int
+	Header      []byte
+	Body        []byte
+	Expiry      int64
+}
+
+// SQLStore 通过 orm.FesDB 保存, 依赖 id 主键保证同一个 key 只有一个请求可以 Lock 成功
+type SQLStore struct {
+	db    *orm.FesDB
+	Table string
+}
+
+func NewSQLStore(db *orm.FesDB, table string) *SQLStore {
+	if table == "" {
+		table = "idempotency_keys"
+	}
+	return &SQLStore{db: db, Table: table}
+}
+
+func (s *SQLStore) Lock(key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	now := time.Now()
+	row := &idempotencyRow{}
+	// 先删除已经过期的记录
+	_, err := s.db.NewSession(row).Exec("delete from "+s.db.Prefix+s.Table+" where id = ? and expiry < ?", key, now.UnixNano())
+	if err != nil {
+		return nil, false, err
+	}
+	row = &idempotencyRow{Id: key, Fingerprint: fingerprint, Header: []byte("{}"), Body: []byte{}, Expiry: now.Add(ttl).UnixNano()}
+	_, _, insertErr := s.db.NewSession(row).Table(s.Table).Insert(row)
+	if insertErr == nil {
+		return nil, true, nil
+	}
+	// 主键冲突, 返回已有的记录
+	record, err := s.get(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if record == nil {
+		return nil, false, insertErr
+	}
+	return record, false, nil
+}
+
+func (s *SQLStore) get(key string) (*Record, error) {
+	row := &idempotencyRow{}
+	err := s.db.NewSession(row).Table(s.Table).Where("id", key).SelectOne(row, "id", "fingerprint", "status", "header", "body", "expiry")
+	if err != nil {
+		return nil, err
+	}
+	if row.Id == "" {
+		return nil, nil
+	}
+	record := &Record{Fingerprint: row.Fingerprint, Status: row.Status, Body: row.Body, Header: http.Header{}}
+	if err := json.Unmarshal(row.Header, &record.Header); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *SQLStore) Refresh(key string, ttl time.Duration) error {
+	row := &idempotencyRow{}
+	_, err := s.db.NewSession(row).Exec("update "+s.db.Prefix+s.Table+" set expiry = ? where id = ? and status = 0", time.Now().Add(ttl).UnixNano(), key)
+	return err
+}
+
+func (s *SQLStore) Save(key string, record *Record, ttl time.Duration) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+	row := &idempotencyRow{}
+	_, err = s.db.NewSession(row).Exec("update "+s.db.Prefix+s.Table+" set status = ?, header = ?, body = ?, expiry = ? where id = ?",
+		record.Status, header, record.Body, time.Now().Add(ttl).UnixNano(), key)
+	return err
+}
+
+func (s *SQLStore) Delete(key string) error {
+	row := &idempotencyRow{}
+	_, err := s.db.NewSession(row).Table(s.Table).Where("id", key).Delete(row)
+	return err
+}
+
+// GC 删除过期的记录, 需要定期调用
+func (s *SQLStore) GC() error {
+	row := &idempotencyRow{}
+	_, err := s.db.NewSession(row).Exec("delete from "+s.db.Prefix+s.Table+" where expiry < ?", time.Now().UnixNano())
+	return err
+}
@@ -0,0 +1,98 @@
+//go:build cgo
+
+// go-sqlite3 需要 cgo
+
+package idempotency
+
+import (
+	"github.com/dalefeng/fesgo/orm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newSQLStore(t *testing.T) *SQLStore {
+	db := orm.Open("sqlite3", filepath.Join(t.TempDir(), "idempotency.db")+"?_busy_timeout=5000")
+	t.Cleanup(func() { db.Close() })
+	_, err := db.NewSession(&idempotencyRow{}).Exec(`create table idempotency_keys (
+		id varchar(255) not null primary key,
+		fingerprint varchar(64) not null,
+		status int not null,
+		header blob not null,
+		body blob not null,
+		expiry bigint not null
+	)`)
+	assert.NoError(t, err)
+	return NewSQLStore(db, "")
+}
+
+func TestSQLStore(t *testing.T) {
+	store := newSQLStore(t)
+
+	record, ok, err := store.Lock("k1", "fp", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, record)
+
+	// 处理中
+	record, ok, err = store.Lock("k1", "fp", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "fp", record.Fingerprint)
+	assert.Equal(t, 0, record.Status)
+	assert.NoError(t, store.Refresh("k1", time.Minute))
+
+	header := http.Header{"Content-Type": []string{"text/plain"}}
+	assert.NoError(t, store.Save("k1", &Record{Fingerprint: "fp", Status: http.StatusCreated, Header: header, Body: []byte("ok")}, time.Hour))
+	record, ok, err = store.Lock("k1", "fp", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusCreated, record.Status)
+	assert.Equal(t, header, record.Header)
+	assert.Equal(t, []byte("ok"), record.Body)
+
+	// 删除后可以重新 Lock
+	assert.NoError(t, store.Delete("k1"))
+	_, ok, err = store.Lock("k1", "fp", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// 过期的记录可以重新 Lock
+	_, ok, err = store.Lock("k2", "fp", -time.Second)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = store.Lock("k2", "fp", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, _, err = store.Lock("k3", "fp", -time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, store.GC())
+	_, ok, err = store.Lock("k3", "fp", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestSQLStoreConcurrentLock(t *testing.T) {
+	store := newSQLStore(t)
+	var locked int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := store.Lock("k1", "fp", time.Minute)
+			assert.NoError(t, err)
+			if ok {
+				atomic.AddInt32(&locked, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&locked))
+}
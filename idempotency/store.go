@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record 一个 Idempotency-Key 对应的请求, Status 为 0 表示第一个请求还在处理中
+type Record struct {
+	Fingerprint string // 请求方法、路径和 body 的哈希, 用于检查 key 是否被不同的请求复用
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// Store 保存 Idempotency-Key 的处理结果, Lock 需要是原子的, 多机部署时需要使用共享的存储
+type Store interface {
+	// Lock key 不存在或已经过期时创建处理中的记录并返回 true, 否则返回已有的记录
+	Lock(key, fingerprint string, ttl time.Duration) (*Record, bool, error)
+	// Refresh 延长处理中的记录的过期时间, 已经保存了响应的记录不受影响
+	Refresh(key string, ttl time.Duration) error
+	// Save 保存第一个请求的响应
+	Save(key string, record *Record, ttl time.Duration) error
+	// Delete 处理失败时删除记录, 客户端可以使用同一个 key 重试
+	Delete(key string) error
+}
+
+const gcInterval = time.Minute
+
+type memoryItem struct {
+	record *Record
+	expiry time.Time
+}
+
+// MemoryStore 内存存储, 只适合单机部署
+type MemoryStore struct {
+	mu     sync.Mutex
+	items  map[string]memoryItem
+	lastGC time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem), lastGC: time.Now()}
+}
+
+func (s *MemoryStore) Lock(key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if item, ok := s.items[key]; ok && now.Before(item.expiry) {
+		record := *item.record
+		return &record, false, nil
+	}
+	s.items[key] = memoryItem{record: &Record{Fingerprint: fingerprint}, expiry: now.Add(ttl)}
+	// 写入时顺便清理过期的记录
+	if now.Sub(s.lastGC) > gcInterval {
+		for k, item := range s.items {
+			if now.After(item.expiry) {
+				delete(s.items, k)
+			}
+		}
+		s.lastGC = now
+	}
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Refresh(key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.items[key]; ok && item.record.Status == 0 {
+		item.expiry = time.Now().Add(ttl)
+		s.items[key] = item
+	}
+	return nil
+}
+
+func (s *MemoryStore) Save(key string, record *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = memoryItem{record: record, expiry: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
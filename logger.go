@@ -6,7 +6,6 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -45,6 +44,7 @@ type LogFormatterParams struct {
 	ClientIP       net.IP
 	Method         string
 	Path           string
+	RequestID      string
 	isDisplayColor bool
 }
 
@@ -89,22 +89,26 @@ var defaultFormatter = func(param *LogFormatterParams) string {
 	if param.Latency > time.Minute {
 		param.Latency = param.Latency.Truncate(time.Second)
 	}
+	requestID := ""
+	if param.RequestID != "" {
+		requestID = " | " + param.RequestID
+	}
 	if param.isDisplayColor {
-		return fmt.Sprintf("%s[fesgo]%s %s %v %s |%s %3d %s| %s %10s %s | %13v |%s %-7s %s  %s %#v %s \n",
+		return fmt.Sprintf("%s[fesgo]%s %s %v %s |%s %3d %s| %s %10s %s | %13v |%s %-7s %s  %s %#v %s%s \n",
 			yellow, reset,
 			blue, param.TimeStamp.Format("2006-01-02 15:04:05"), reset,
 			param.StatusCodeColor(), param.StatusCode, reset,
 			red, param.Latency, reset,
 			param.ClientIP,
 			param.MethodColor(), param.Method, reset,
-			cyan, param.Path, reset)
+			cyan, param.Path, reset, requestID)
 	}
-	return fmt.Sprintf("[fesgo] %s | %3d | %10s | %13v | %-7s %#v \n",
+	return fmt.Sprintf("[fesgo] %s | %3d | %10s | %13v | %-7s %#v%s \n",
 		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
 		param.StatusCode,
 		param.Latency,
 		param.ClientIP,
-		param.Method, param.Path)
+		param.Method, param.Path, requestID)
 }
 
 func LoggingWithConfig(config LoggingConfig, next HandlerFunc) HandlerFunc {
@@ -131,8 +135,7 @@ func LoggingWithConfig(config LoggingConfig, next HandlerFunc) HandlerFunc {
 		next(c)
 		stop := time.Now()
 		latency := stop.Sub(start)
-		ip, _, _ := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
-		clientIP := net.ParseIP(ip)
+		clientIP := net.ParseIP(c.ClientIP())
 
 		path := r.URL.Path
 		raw := r.URL.RawQuery
@@ -147,6 +150,7 @@ func LoggingWithConfig(config LoggingConfig, next HandlerFunc) HandlerFunc {
 			ClientIP:       clientIP,
 			Method:         r.Method,
 			Path:           path,
+			RequestID:      c.RequestID(),
 			isDisplayColor: displayColor,
 		}
 		fmt.Fprint(out, formatter(param))
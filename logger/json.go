@@ -13,9 +13,12 @@ type JsonFormatter struct {
 func (f *JsonFormatter) Format(params *FormatterParams) string {
 	now := time.Now().Format("2006-01-02 15:04:05")
 
-	if params.Fields == nil {
-		params.Fields = make(Fields)
+	// 复制一份, 不修改 Logger 的字段
+	fields := make(Fields, len(params.Fields)+4)
+	for k, v := range params.Fields {
+		fields[k] = v
 	}
+	params.Fields = fields
 	if f.TimeDisplay {
 		params.Fields["time"] = now
 	}
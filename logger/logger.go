@@ -154,6 +154,19 @@ func (l *Logger) WithFields(fields Fields) *Logger {
 	return l
 }
 
+// With 返回带有附加字段的 Logger, 不修改原来的 Logger, 可以用于单个请求
+func (l *Logger) With(fields Fields) *Logger {
+	logger := *l
+	logger.Fields = make(Fields, len(l.Fields)+len(fields))
+	for k, v := range l.Fields {
+		logger.Fields[k] = v
+	}
+	for k, v := range fields {
+		logger.Fields[k] = v
+	}
+	return &logger
+}
+
 func (l *Logger) SetLevel(level Level) {
 	l.Level = level
 }
@@ -208,8 +221,9 @@ func (l *Logger) SetLoggerPath(logPathDir string) {
 }
 
 func (l *Logger) CheckFileSize(out *LoggerWriter) {
-	logFile := out.W.(*os.File)
-	if logFile == nil {
+	// 只检查文件, 其他 io.Writer 直接写入
+	logFile, ok := out.W.(*os.File)
+	if !ok || logFile == nil || l.LogPathDir == "" {
 		return
 	}
 	// 判断文件大小
@@ -0,0 +1,42 @@
+package fesgo
+
+import (
+	"log"
+	"os"
+)
+
+const EnvFesMode = "FES_MODE"
+
+const (
+	DebugMode   = "debug"
+	ReleaseMode = "release"
+	TestMode    = "test"
+)
+
+var fesMode = ReleaseMode
+
+func init() {
+	SetMode(os.Getenv(EnvFesMode))
+}
+
+// SetMode 设置运行模式, 为空时使用 release 模式, debug 模式下模板文件变化时自动重新编译
+// 未知的模式记录警告并使用 release 模式
+func SetMode(mode string) {
+	switch mode {
+	case "":
+		fesMode = ReleaseMode
+	case DebugMode, ReleaseMode, TestMode:
+		fesMode = mode
+	default:
+		log.Printf("[WARNING] fesgo mode unknown: %q, using %s mode", mode, ReleaseMode)
+		fesMode = ReleaseMode
+	}
+}
+
+func Mode() string {
+	return fesMode
+}
+
+func IsDebugging() bool {
+	return fesMode == DebugMode
+}
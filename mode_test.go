@@ -0,0 +1,17 @@
+package fesgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetMode(t *testing.T) {
+	defer SetMode(Mode())
+	SetMode("")
+	assert.Equal(t, ReleaseMode, Mode())
+	SetMode(DebugMode)
+	assert.True(t, IsDebugging())
+	// 未知的模式不再 panic
+	assert.NotPanics(t, func() { SetMode("prod") })
+	assert.Equal(t, ReleaseMode, Mode())
+}
@@ -0,0 +1,199 @@
+package fesgo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dalefeng/fesgo/binding"
+	"github.com/dalefeng/fesgo/render"
+	"google.golang.org/protobuf/proto"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NegotiateConfig 内容协商的候选数据, 各格式没有单独设置数据时使用 Data
+type NegotiateConfig struct {
+	Offered  []string // 候选的 MIME 类型, 按优先级排列, 为空时按设置了数据的格式推断
+	HTMLName string   // 模板名, 为空时 HTML 数据转义后输出, template.HTML 类型原样输出
+	HTML     any
+	JSON     any
+	XML      any
+	YAML     any
+	TOML     any
+	ProtoBuf any
+	MsgPack  any
+	Data     any
+}
+
+type acceptRange struct {
+	mime        string
+	q           float64
+	specificity int
+}
+
+// parseAccept 解析 Accept, 按 q 值和精确程度从高到低排序
+func parseAccept(accept string) []acceptRange {
+	parts := strings.Split(accept, ",")
+	ranges := make([]acceptRange, 0, len(parts))
+	for _, part := range parts {
+		params := strings.Split(part, ";")
+		mime := strings.ToLower(strings.TrimSpace(params[0]))
+		if mime == "" {
+			continue
+		}
+		if mime == "*" {
+			mime = "*/*"
+		}
+		ar := acceptRange{mime: mime, q: 1}
+		for _, param := range params[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			ar.q = q
+		}
+		switch {
+		case mime == "*/*":
+			ar.specificity = 0
+		case strings.HasSuffix(mime, "/*"):
+			ar.specificity = 1
+		default:
+			ar.specificity = 2
+		}
+		ranges = append(ranges, ar)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity > ranges[j].specificity
+	})
+	return ranges
+}
+
+func matchMime(accept, offer string) bool {
+	if accept == "*/*" || accept == offer {
+		return true
+	}
+	if strings.HasSuffix(accept, "/*") {
+		return strings.HasPrefix(offer, accept[:len(accept)-1])
+	}
+	return false
+}
+
+// acceptQuality 返回最精确匹配 offer 的 q 值, 没有匹配时返回 -1
+func acceptQuality(ranges []acceptRange, offer string) float64 {
+	q, specificity := -1.0, -1
+	for _, ar := range ranges {
+		if ar.specificity > specificity && matchMime(ar.mime, offer) {
+			q, specificity = ar.q, ar.specificity
+		}
+	}
+	return q
+}
+
+// NegotiateFormat 根据 Accept 从 offers 中选出最合适的类型, 没有可接受的类型时返回空字符串
+func (c *Context) NegotiateFormat(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accept := c.R.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		// q=0 表示明确不接受, 即使有更宽泛的范围匹配
+		q := acceptQuality(ranges, strings.ToLower(offer))
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Negotiate 根据 Accept 选择响应格式, 没有可接受的格式时返回 406
+func (c *Context) Negotiate(status int, config NegotiateConfig) {
+	c.W.Header().Add("Vary", "Accept")
+	offers := config.Offered
+	if len(offers) == 0 {
+		offers = config.offers()
+	}
+	switch c.NegotiateFormat(offers...) {
+	case binding.MIMEJSON:
+		c.JSON(status, config.pick(config.JSON))
+	case binding.MIMEHTML:
+		data := config.pick(config.HTML)
+		if config.HTMLName == "" {
+			html, ok := data.(template.HTML)
+			if !ok {
+				html = template.HTML(template.HTMLEscapeString(fmt.Sprint(data)))
+			}
+			c.renderTemplate(status, &render.HTML{Data: string(html)})
+			return
+		}
+		if c.engine.HTMLRender == nil {
+			c.templateError(errors.New("html render is not set"))
+			return
+		}
+		c.renderTemplate(status, c.engine.HTMLRender.Instance(config.HTMLName, data))
+	case binding.MIMEXML, binding.MIMEXML2:
+		c.XML(status, config.pick(config.XML))
+	case binding.MIMEYAML, binding.MIMEYAML2:
+		c.YAML(status, config.pick(config.YAML))
+	case binding.MIMETOML:
+		c.TOML(status, config.pick(config.TOML))
+	case binding.MIMEPROTOBUF:
+		msg, ok := config.pick(config.ProtoBuf).(proto.Message)
+		if !ok {
+			c.Abort(errors.New("data is not proto.Message"))
+			return
+		}
+		c.ProtoBuf(status, msg)
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		c.MsgPack(status, config.pick(config.MsgPack))
+	default:
+		c.String(http.StatusNotAcceptable, "the accepted formats are not offered by the server")
+	}
+}
+
+// offers 按设置了数据的格式推断候选类型, JSON 优先
+func (n NegotiateConfig) offers() []string {
+	offers := make([]string, 0)
+	if n.JSON != nil || n.Data != nil {
+		offers = append(offers, binding.MIMEJSON)
+	}
+	if n.HTMLName != "" || n.HTML != nil {
+		offers = append(offers, binding.MIMEHTML)
+	}
+	if n.XML != nil || n.Data != nil {
+		offers = append(offers, binding.MIMEXML, binding.MIMEXML2)
+	}
+	if n.YAML != nil || n.Data != nil {
+		offers = append(offers, binding.MIMEYAML2, binding.MIMEYAML)
+	}
+	if n.TOML != nil || n.Data != nil {
+		offers = append(offers, binding.MIMETOML)
+	}
+	if n.ProtoBuf != nil {
+		offers = append(offers, binding.MIMEPROTOBUF)
+	}
+	if n.MsgPack != nil || n.Data != nil {
+		offers = append(offers, binding.MIMEMSGPACK2, binding.MIMEMSGPACK)
+	}
+	return offers
+}
+
+func (n NegotiateConfig) pick(data any) any {
+	if data != nil {
+		return data
+	}
+	return n.Data
+}
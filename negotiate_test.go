@@ -0,0 +1,110 @@
+package fesgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	testCase := []struct {
+		name   string
+		accept string
+		offers []string
+		want   string
+	}{
+		{
+			name:   "empty accept",
+			offers: []string{"application/json", "text/html"},
+			want:   "application/json",
+		},
+		{
+			name:   "browser",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			offers: []string{"application/json", "text/html"},
+			want:   "text/html",
+		},
+		{
+			name:   "q value",
+			accept: "application/xml;q=0.5, application/json;q=0.9",
+			offers: []string{"application/xml", "application/json"},
+			want:   "application/json",
+		},
+		{
+			name:   "wildcard subtype",
+			accept: "text/*",
+			offers: []string{"application/json", "text/html"},
+			want:   "text/html",
+		},
+		{
+			name:   "explicit refuse",
+			accept: "application/json;q=0, */*",
+			offers: []string{"application/json", "application/xml"},
+			want:   "application/xml",
+		},
+		{
+			name:   "not acceptable",
+			accept: "image/png",
+			offers: []string{"application/json"},
+			want:   "",
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tc.accept)
+			ctx := &Context{R: r, W: httptest.NewRecorder()}
+			assert.Equal(tt, tc.want, ctx.NegotiateFormat(tc.offers...))
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	testCase := []struct {
+		name        string
+		accept      string
+		wantCode    int
+		contentType string
+	}{
+		{name: "json", accept: "application/json", wantCode: http.StatusOK, contentType: "application/json; charset=utf-8"},
+		{name: "xml", accept: "text/xml", wantCode: http.StatusOK, contentType: "application/xml; charset=utf-8"},
+		{name: "yaml", accept: "application/yaml", wantCode: http.StatusOK, contentType: "application/yaml; charset=utf-8"},
+		{name: "not acceptable", accept: "image/png", wantCode: http.StatusNotAcceptable, contentType: "text/plain; charset=utf-8"},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			ctx := &Context{R: r, W: w}
+			ctx.Negotiate(http.StatusOK, NegotiateConfig{Data: &bodyUser{Name: "feng"}})
+			assert.Equal(tt, tc.wantCode, w.Code)
+			assert.Equal(tt, tc.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestNegotiateHTMLEscape(t *testing.T) {
+	testCase := []struct {
+		name   string
+		config NegotiateConfig
+		want   string
+	}{
+		{name: "escape", config: NegotiateConfig{HTML: "<script>alert(1)</script>"}, want: "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{name: "data", config: NegotiateConfig{Data: "<b>", Offered: []string{"text/html"}}, want: "&lt;b&gt;"},
+		{name: "trusted", config: NegotiateConfig{HTML: template.HTML("<b>ok</b>")}, want: "<b>ok</b>"},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", "text/html")
+			w := httptest.NewRecorder()
+			ctx := &Context{R: r, W: w}
+			ctx.Negotiate(http.StatusOK, tc.config)
+			assert.Equal(tt, http.StatusOK, w.Code)
+			assert.Equal(tt, tc.want, w.Body.String())
+		})
+	}
+}
@@ -326,6 +326,7 @@ func (s *FesSession) SelectOne(data any, fields ...string) error {
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 	columns, err := rows.Columns()
 	if err != nil {
 		return err
@@ -395,7 +396,7 @@ func (s *FesSession) Exec(sql string, values ...any) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	exec, err := stmt.Exec(values)
+	exec, err := stmt.Exec(values...)
 	if err != nil {
 		return 0, err
 	}
@@ -414,10 +415,11 @@ func (s *FesSession) QueryRaw(sql string, data any, queryValues ...any) error {
 	if err != nil {
 		return err
 	}
-	rows, err := stmt.Query(queryValues)
+	rows, err := stmt.Query(queryValues...)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	columns, err := rows.Columns()
 	if err != nil {
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Result 一次请求的限流结果
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // 配额完全恢复需要的时间
+	RetryAfter time.Duration // 被拒绝时需要等待的时间
+}
+
+type Limiter interface {
+	Take(key string) (Result, error)
+}
+
+// TokenBucket 令牌桶, 每个 Period 产生 Limit 个令牌, 最多积累 Burst 个, 允许短时间的突发请求
+type TokenBucket struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+	Store  Store
+	Prefix string // 多个限流器共享存储时区分 key
+
+	now func() time.Time
+}
+
+func NewTokenBucket(limit int, period time.Duration, burst int) *TokenBucket {
+	if burst <= 0 {
+		burst = limit
+	}
+	b := &TokenBucket{Limit: limit, Period: period, Burst: burst, Store: NewMemoryStore(), now: time.Now}
+	b.validate()
+	return b
+}
+
+func (b *TokenBucket) validate() {
+	if b.Limit <= 0 || b.Period <= 0 || b.Burst <= 0 {
+		panic("ratelimit: TokenBucket limit, period and burst must be positive")
+	}
+	if b.Store == nil {
+		panic("ratelimit: TokenBucket store is required")
+	}
+}
+
+func (b *TokenBucket) Take(key string) (Result, error) {
+	now := currentTime(b.now)
+	interval := float64(b.Period) / float64(b.Limit) // 产生一个令牌需要的纳秒数
+	capacity := float64(b.Burst)
+	result := Result{Limit: b.Burst}
+	ttl := duration(capacity * interval)
+	err := b.Store.Update(b.Prefix+key, ttl, func(s *State) {
+		tokens := capacity
+		if s.Time != 0 {
+			tokens = math.Min(capacity, s.Tokens+float64(now.UnixNano()-s.Time)/interval)
+		}
+		if tokens >= 1 {
+			tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = duration((1 - tokens) * interval)
+		}
+		s.Tokens = tokens
+		s.Time = now.UnixNano()
+		result.Remaining = int(tokens)
+		result.Reset = duration((capacity - tokens) * interval)
+	})
+	return result, err
+}
+
+// currentTime 测试时可以替换当前时间
+func currentTime(now func() time.Time) time.Time {
+	if now == nil {
+		return time.Now()
+	}
+	return now()
+}
+
+// duration 纳秒数转换为时间, 消除浮点数计算的误差
+func duration(ns float64) time.Duration {
+	return time.Duration(math.Round(ns))
+}
+
+// SlidingWindow 滑动窗口, 使用当前窗口和上一个窗口的计数按时间加权估算 Window 内的请求数
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+	Store  Store
+	Prefix string
+
+	now func() time.Time
+}
+
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	w := &SlidingWindow{Limit: limit, Window: window, Store: NewMemoryStore(), now: time.Now}
+	w.validate()
+	return w
+}
+
+func (w *SlidingWindow) validate() {
+	if w.Limit <= 0 || w.Window <= 0 {
+		panic("ratelimit: SlidingWindow limit and window must be positive")
+	}
+	if w.Store == nil {
+		panic("ratelimit: SlidingWindow store is required")
+	}
+}
+
+func (w *SlidingWindow) Take(key string) (Result, error) {
+	now := currentTime(w.now).UnixNano()
+	window := int64(w.Window)
+	start := now - now%window
+	limit := float64(w.Limit)
+	result := Result{Limit: w.Limit}
+	err := w.Store.Update(w.Prefix+key, 2*w.Window, func(s *State) {
+		if s.Time != start {
+			if s.Time == start-window {
+				s.PrevCount = s.Count
+			} else {
+				s.PrevCount = 0
+			}
+			s.Count = 0
+			s.Time = start
+		}
+		elapsed := float64(now-start) / float64(window)
+		prev, count := float64(s.PrevCount), float64(s.Count)
+		estimated := prev*(1-elapsed) + count
+		if estimated+1 <= limit {
+			s.Count++
+			result.Allowed = true
+			estimated++
+		} else {
+			result.RetryAfter = w.retryAfter(prev, count, now, start)
+		}
+		result.Remaining = int(math.Max(0, limit-math.Ceil(estimated)))
+		result.Reset = time.Duration(start + window - now)
+		if s.Count > 0 {
+			// 当前窗口的请求在下一个窗口结束时才完全不计入
+			result.Reset += w.Window
+		}
+	})
+	return result, err
+}
+
+// retryAfter 估算的请求数降到 Limit-1 以下需要的时间
+func (w *SlidingWindow) retryAfter(prev, count float64, now, start int64) time.Duration {
+	window := float64(w.Window)
+	need := float64(w.Limit) - 1 - count
+	if need >= 0 && prev > 0 {
+		// 当前窗口内上一个窗口的权重降低后可以通过
+		at := float64(start) + (1-need/prev)*window
+		return duration(at - float64(now))
+	}
+	// 当前窗口已满, 等到下一个窗口中当前窗口的权重足够低
+	at := float64(start) + window
+	if count > 0 {
+		at += math.Max(0, 1-(float64(w.Limit)-1)/count) * window
+	}
+	return duration(at - float64(now))
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/dalefeng/fesgo"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	Limiter Limiter
+	// KeyFunc 限流的 key, 默认 KeyByIP, 返回空字符串时不限流
+	KeyFunc func(ctx *fesgo.Context) string
+	// LimitHandler 超过限制时调用, 默认返回 429
+	LimitHandler func(ctx *fesgo.Context, result Result)
+	// ErrorHandler 存储出错时调用, 为空时记录日志并放行
+	ErrorHandler func(ctx *fesgo.Context, err error)
+}
+
+// New 限流中间件, 响应中添加 RateLimit-Limit、RateLimit-Remaining 和 RateLimit-Reset, 被拒绝时添加 Retry-After
+func New(config Config) fesgo.MiddlewareFunc {
+	if config.Limiter == nil {
+		panic("ratelimit: Limiter is required")
+	}
+	// 直接构造的限流器在这里检查参数, 避免第一个请求时才出错
+	if v, ok := config.Limiter.(interface{ validate() }); ok {
+		v.validate()
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = KeyByIP
+	}
+	if config.LimitHandler == nil {
+		config.LimitHandler = defaultLimitHandler
+	}
+	return func(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+		return func(ctx *fesgo.Context) {
+			key := config.KeyFunc(ctx)
+			if key == "" {
+				next(ctx)
+				return
+			}
+			result, err := config.Limiter.Take(key)
+			if err != nil {
+				if config.ErrorHandler != nil {
+					config.ErrorHandler(ctx, err)
+					return
+				}
+				// 存储不可用时不影响正常请求
+				if ctx.Logger != nil {
+					ctx.Logger.Error(fmt.Sprintf("ratelimit: %v", err))
+				}
+				next(ctx)
+				return
+			}
+			header := ctx.W.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+				config.LimitHandler(ctx, result)
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+func defaultLimitHandler(ctx *fesgo.Context, result Result) {
+	ctx.String(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+}
+
+// seconds 向上取整的秒数
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// KeyByIP 按客户端 IP 限流, 经过代理时需要设置 Engine.SetTrustedProxies
+func KeyByIP(ctx *fesgo.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// KeyByUser 按登录用户限流, 依次使用 Context 中的 user 和 jwt_claims 中的 sub, 没有登录时按 IP 限流
+func KeyByUser(ctx *fesgo.Context) string {
+	if user, ok := ctx.Get("user"); ok {
+		if s := fmt.Sprint(user); s != "" {
+			return "user:" + s
+		}
+	}
+	if key := KeyByClaim("sub")(ctx); key != "" {
+		return key
+	}
+	return KeyByIP(ctx)
+}
+
+// KeyByClaim 按 jwt_claims 中的字段限流, 字段不存在时不限流, 需要在 jwt 中间件之后执行
+func KeyByClaim(name string) func(ctx *fesgo.Context) string {
+	return func(ctx *fesgo.Context) string {
+		value, ok := ctx.Get("jwt_claims")
+		if !ok {
+			return ""
+		}
+		claims, ok := value.(jwt.MapClaims)
+		if !ok {
+			return ""
+		}
+		claim, ok := claims[name]
+		if !ok || claim == nil {
+			return ""
+		}
+		return "claim:" + name + ":" + fmt.Sprint(claim)
+	}
+}
@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"errors"
+	"github.com/dalefeng/fesgo"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// clock 测试使用的时间, 手动前进
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestTokenBucket(t *testing.T) {
+	c := &clock{t: time.Unix(1000, 0)}
+	b := NewTokenBucket(2, time.Second, 4)
+	b.now = c.now
+
+	// 突发 4 个, 之后每 500ms 一个
+	for i := 0; i < 4; i++ {
+		r, err := b.Take("a")
+		assert.NoError(t, err)
+		assert.True(t, r.Allowed)
+		assert.Equal(t, 3-i, r.Remaining)
+	}
+	r, _ := b.Take("a")
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 500*time.Millisecond, r.RetryAfter)
+	assert.Equal(t, 2*time.Second, r.Reset)
+
+	// 其他 key 不受影响
+	r, _ = b.Take("b")
+	assert.True(t, r.Allowed)
+
+	c.add(500 * time.Millisecond)
+	r, _ = b.Take("a")
+	assert.True(t, r.Allowed)
+	r, _ = b.Take("a")
+	assert.False(t, r.Allowed)
+
+	c.add(time.Hour)
+	r, _ = b.Take("a")
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 3, r.Remaining)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	c := &clock{t: time.Unix(1000, 0)}
+	w := NewSlidingWindow(4, time.Second)
+	w.now = c.now
+
+	for i := 0; i < 4; i++ {
+		r, _ := w.Take("a")
+		assert.True(t, r.Allowed)
+		assert.Equal(t, 3-i, r.Remaining)
+	}
+	r, _ := w.Take("a")
+	assert.False(t, r.Allowed)
+	// 下一个窗口过去 1/4 后, 上一个窗口的权重为 3/4, 估算为 3
+	assert.Equal(t, 1250*time.Millisecond, r.RetryAfter)
+
+	c.add(time.Second)
+	r, _ = w.Take("a")
+	assert.False(t, r.Allowed)
+	c.add(250 * time.Millisecond)
+	r, _ = w.Take("a")
+	assert.True(t, r.Allowed)
+	r, _ = w.Take("a")
+	assert.False(t, r.Allowed)
+
+	c.add(10 * time.Second)
+	r, _ = w.Take("a")
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 3, r.Remaining)
+}
+
+func TestMemoryStoreShards(t *testing.T) {
+	s := NewMemoryStore()
+	for i := 0; i < 100; i++ {
+		s.Update(string(rune('a'+i)), time.Minute, func(state *State) {
+			state.Count++
+		})
+	}
+	assert.Equal(t, 100, s.Len())
+}
+
+type errorStore struct{}
+
+func (errorStore) Update(key string, ttl time.Duration, fn func(state *State)) error {
+	return errors.New("store unavailable")
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter := NewTokenBucket(1, time.Minute, 2)
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(New(Config{Limiter: limiter}))
+	g.Get("/login", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	failing := &SlidingWindow{Limit: 1, Window: time.Second, Store: errorStore{}}
+	g.Get("/open", func(ctx *fesgo.Context) {
+		ctx.String(http.StatusOK, "ok")
+	}, New(Config{Limiter: failing}))
+
+	do := func(path, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	w := do("/api/login", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+	do("/api/login", "10.0.0.1")
+	w = do("/api/login", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, do("/api/login", "10.0.0.2").Code)
+
+	// 存储出错时放行
+	assert.Equal(t, http.StatusOK, do("/api/open", "10.0.0.3").Code)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	assert.Panics(t, func() { New(Config{}) })
+	assert.Panics(t, func() { NewTokenBucket(0, time.Second, 0) })
+	assert.Panics(t, func() { NewTokenBucket(1, 0, 0) })
+	assert.Panics(t, func() { NewSlidingWindow(1, 0) })
+	assert.Panics(t, func() { New(Config{Limiter: &TokenBucket{Limit: 1, Store: NewMemoryStore()}}) })
+	assert.Panics(t, func() { New(Config{Limiter: &SlidingWindow{Window: time.Second, Store: NewMemoryStore()}}) })
+	assert.NotPanics(t, func() { New(Config{Limiter: NewSlidingWindow(1, time.Second)}) })
+}
+
+func TestKeyFunc(t *testing.T) {
+	ctx := &fesgo.Context{R: httptest.NewRequest(http.MethodGet, "/", nil)}
+	ctx.R.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "ip:10.0.0.1", KeyByIP(ctx))
+	assert.Equal(t, "ip:10.0.0.1", KeyByUser(ctx))
+	assert.Equal(t, "", KeyByClaim("sub")(ctx))
+
+	ctx.Set("jwt_claims", jwt.MapClaims{"sub": "42", "tenant": "t1"})
+	assert.Equal(t, "claim:sub:42", KeyByUser(ctx))
+	assert.Equal(t, "claim:tenant:t1", KeyByClaim("tenant")(ctx))
+
+	ctx.Set("user", "feng")
+	assert.Equal(t, "user:feng", KeyByUser(ctx))
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// State 限流状态, 不同算法使用不同的字段
+type State struct {
+	Tokens    float64 // 令牌桶剩余的令牌
+	Count     int64   // 滑动窗口当前窗口的请求数
+	PrevCount int64   // 滑动窗口上一个窗口的请求数
+	Time      int64   // 令牌桶上次更新的时间或当前窗口的开始时间, UnixNano, 0 表示新的 key
+}
+
+// Store 保存限流状态, 同一个 key 的 Update 需要是原子的
+// 分布式部署时可以使用 redis 等实现, ttl 之后状态可以删除
+type Store interface {
+	Update(key string, ttl time.Duration, fn func(state *State)) error
+}
+
+const shardCount = 64
+
+// MemoryStore 分片的内存存储, 减少锁竞争
+type MemoryStore struct {
+	shards [shardCount]*shard
+}
+
+type shard struct {
+	mu     sync.Mutex
+	items  map[string]*entry
+	lastGC time.Time
+}
+
+type entry struct {
+	state  State
+	expiry time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	for i := range s.shards {
+		s.shards[i] = &shard{items: make(map[string]*entry), lastGC: time.Now()}
+	}
+	return s
+}
+
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state *State)) error {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	sh := s.shards[h.Sum32()%shardCount]
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	now := time.Now()
+	e, ok := sh.items[key]
+	if !ok || now.After(e.expiry) {
+		e = &entry{}
+		sh.items[key] = e
+	}
+	fn(&e.state)
+	e.expiry = now.Add(ttl)
+	// 定期清理过期的 key
+	if now.Sub(sh.lastGC) > time.Minute {
+		for k, item := range sh.items {
+			if now.After(item.expiry) {
+				delete(sh.items, k)
+			}
+		}
+		sh.lastGC = now
+	}
+	return nil
+}
+
+// Len 保存的 key 数量, 包括还没有清理的过期 key
+func (s *MemoryStore) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		n += len(sh.items)
+		sh.mu.Unlock()
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package render
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+func (d *Data) Render(w http.ResponseWriter) error {
+	d.WriterContentType(w)
+	_, err := w.Write(d.Data)
+	return err
+}
+
+func (d *Data) WriterContentType(w http.ResponseWriter) {
+	if d.ContentType != "" {
+		writerContentType(w, d.ContentType)
+	}
+}
+
+// Reader 从 io.Reader 复制响应内容, ContentLength 小于 0 时不设置 Content-Length
+type Reader struct {
+	ContentType   string
+	ContentLength int64
+	Reader        io.Reader
+	Headers       map[string]string
+}
+
+func (r *Reader) Render(w http.ResponseWriter) error {
+	r.WriterContentType(w)
+	_, err := io.Copy(w, r.Reader)
+	return err
+}
+
+func (r *Reader) WriterContentType(w http.ResponseWriter) {
+	header := w.Header()
+	for k, v := range r.Headers {
+		if header.Get(k) == "" {
+			header.Set(k, v)
+		}
+	}
+	if r.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	if r.ContentType != "" {
+		writerContentType(w, r.ContentType)
+	}
+}
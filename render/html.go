@@ -1,28 +1,61 @@
 package render
 
 import (
+	"bytes"
 	"github.com/dalefeng/fesgo/render/internal/bytescovn"
 	"html/template"
 	"net/http"
+	"sync"
 )
 
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
 type HTML struct {
 	Name       string
 	Data       any
 	Template   *template.Template
+	Manager    *TemplateManager
 	IsTemplate bool
 }
 
-type HTMLRender struct {
+// HTMLRender 模板引擎, 可以替换为其他实现
+type HTMLRender interface {
+	Instance(name string, data any) Render
+}
+
+// HTMLProduction 使用编译好的 html/template
+type HTMLProduction struct {
 	Template *template.Template
 }
 
+func (r HTMLProduction) Instance(name string, data any) Render {
+	return &HTML{Name: name, Data: data, Template: r.Template, IsTemplate: true}
+}
+
 func (r *HTML) Render(w http.ResponseWriter) error {
-	r.WriterContentType(w)
 	if r.IsTemplate {
-		err := r.Template.ExecuteTemplate(w, r.Name, r.Data)
+		// 先渲染到缓冲区, 出错时不会写入半截的 HTML
+		buf := bufferPool.Get().(*bytes.Buffer)
+		buf.Reset()
+		defer bufferPool.Put(buf)
+		var err error
+		if r.Manager != nil {
+			err = r.Manager.ExecuteTemplate(buf, r.Name, r.Data)
+		} else {
+			err = r.Template.ExecuteTemplate(buf, r.Name, r.Data)
+		}
+		if err != nil {
+			return err
+		}
+		r.WriterContentType(w)
+		_, err = w.Write(buf.Bytes())
 		return err
 	}
+	r.WriterContentType(w)
 	_, err := w.Write(bytescovn.StringToBytes(r.Data.(string)))
 	return err
 }
@@ -1,17 +1,59 @@
 package render
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/dalefeng/fesgo/codec"
+	"github.com/dalefeng/fesgo/render/internal/bytescovn"
 	"net/http"
+	"reflect"
+	"regexp"
+	"unicode/utf16"
 )
 
+var ErrInvalidCallback = errors.New("invalid jsonp callback")
+
+var callbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][0-9a-zA-Z_$]*(\.[a-zA-Z_$][0-9a-zA-Z_$]*)*$`)
+
 type Json struct {
 	Data any
 }
 
+// IndentedJSON 格式化输出
+type IndentedJSON struct {
+	Data any
+}
+
+// SecureJSON 数组类型的响应加上前缀, 防止 json 劫持
+type SecureJSON struct {
+	Prefix string
+	Data   any
+}
+
+type JSONP struct {
+	Callback string
+	Data     any
+}
+
+// AsciiJSON 非 ASCII 字符转换为 \uXXXX
+type AsciiJSON struct {
+	Data any
+}
+
+// PureJSON 不转义 HTML 字符
+type PureJSON struct {
+	Data any
+}
+
+// StreamJSON 切片、数组和 chan 逐个元素编码直接写入响应, 不在内存中缓冲整个结果
+type StreamJSON struct {
+	Data any
+}
+
 func (j *Json) Render(w http.ResponseWriter) error {
 	j.WriterContentType(w)
-	jsonData, err := json.Marshal(j.Data)
+	jsonData, err := codec.JSON.Marshal(j.Data)
 	if err != nil {
 		return err
 	}
@@ -22,3 +64,152 @@ func (j *Json) Render(w http.ResponseWriter) error {
 func (j *Json) WriterContentType(w http.ResponseWriter) {
 	writerContentType(w, "application/json; charset=utf-8")
 }
+
+func (j *IndentedJSON) Render(w http.ResponseWriter) error {
+	j.WriterContentType(w)
+	jsonData, err := codec.JSON.MarshalIndent(j.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+func (j *IndentedJSON) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/json; charset=utf-8")
+}
+
+func (j *SecureJSON) Render(w http.ResponseWriter) error {
+	j.WriterContentType(w)
+	jsonData, err := codec.JSON.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(jsonData, []byte("[")) && bytes.HasSuffix(jsonData, []byte("]")) {
+		if _, err = w.Write(bytescovn.StringToBytes(j.Prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+func (j *SecureJSON) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/json; charset=utf-8")
+}
+
+// ValidCallback 校验 jsonp 回调函数名, 只允许 js 标识符和点号
+func ValidCallback(callback string) bool {
+	return callbackRegexp.MatchString(callback)
+}
+
+func (j *JSONP) Render(w http.ResponseWriter) error {
+	j.WriterContentType(w)
+	if !ValidCallback(j.Callback) {
+		return ErrInvalidCallback
+	}
+	jsonData, err := codec.JSON.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	// 前置注释防止回调名被当作其他内容解析
+	_, err = fmt.Fprintf(w, "/**/%s(%s);", j.Callback, jsonData)
+	return err
+}
+
+func (j *JSONP) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/javascript; charset=utf-8")
+}
+
+func (j *AsciiJSON) Render(w http.ResponseWriter) error {
+	j.WriterContentType(w)
+	jsonData, err := codec.JSON.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, r := range string(jsonData) {
+		if r < 128 {
+			buf.WriteRune(r)
+			continue
+		}
+		if r > 0xFFFF {
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&buf, "\\u%04x\\u%04x", r1, r2)
+			continue
+		}
+		fmt.Fprintf(&buf, "\\u%04x", r)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (j *AsciiJSON) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/json")
+}
+
+func (j *PureJSON) Render(w http.ResponseWriter) error {
+	j.WriterContentType(w)
+	encoder := codec.JSON.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(j.Data)
+}
+
+func (j *PureJSON) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/json; charset=utf-8")
+}
+
+func (j *StreamJSON) Render(w http.ResponseWriter) error {
+	j.WriterContentType(w)
+	encoder := codec.JSON.NewEncoder(w)
+	v := reflect.ValueOf(j.Data)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			_, err := w.Write([]byte("null"))
+			return err
+		}
+		return j.stream(w, encoder, func(i int) (any, bool) {
+			if i >= v.Len() {
+				return nil, false
+			}
+			return v.Index(i).Interface(), true
+		})
+	case reflect.Chan:
+		return j.stream(w, encoder, func(int) (any, bool) {
+			elem, ok := v.Recv()
+			if !ok {
+				return nil, false
+			}
+			return elem.Interface(), true
+		})
+	default:
+		return encoder.Encode(j.Data)
+	}
+}
+
+func (j *StreamJSON) stream(w http.ResponseWriter, encoder codec.JSONEncoder, next func(i int) (any, bool)) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		elem, ok := next(i)
+		if !ok {
+			break
+		}
+		if i > 0 {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(elem); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte("]"))
+	return err
+}
+
+func (j *StreamJSON) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/json; charset=utf-8")
+}
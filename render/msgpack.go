@@ -0,0 +1,20 @@
+package render
+
+import (
+	"net/http"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type MsgPack struct {
+	Data any
+}
+
+func (m *MsgPack) Render(w http.ResponseWriter) error {
+	m.WriterContentType(w)
+	return msgpack.NewEncoder(w).Encode(m.Data)
+}
+
+func (m *MsgPack) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/msgpack")
+}
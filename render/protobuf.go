@@ -0,0 +1,25 @@
+package render
+
+import (
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type ProtoBuf struct {
+	Data proto.Message
+}
+
+func (p *ProtoBuf) Render(w http.ResponseWriter) error {
+	p.WriterContentType(w)
+	bytes, err := proto.Marshal(p.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes)
+	return err
+}
+
+func (p *ProtoBuf) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/x-protobuf")
+}
@@ -13,7 +13,7 @@ type Redirect struct {
 
 func (r *Redirect) Render(w http.ResponseWriter) error {
 	if (r.Code < http.StatusMultipleChoices || r.Code > http.StatusPermanentRedirect) && r.Code != http.StatusCreated {
-		panic(fmt.Sprintf("cannot redirect with status code %d", r.Code))
+		return fmt.Errorf("cannot redirect with status code %d", r.Code)
 	}
 	http.Redirect(w, r.Request, r.Location, r.Code)
 	return nil
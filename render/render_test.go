@@ -0,0 +1,146 @@
+package render
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJsonRender(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	close(ch)
+
+	testCase := []struct {
+		name        string
+		render      Render
+		want        string
+		contentType string
+		wantErr     error
+	}{
+		{
+			name:        "json",
+			render:      &Json{Data: map[string]any{"name": "<b>"}},
+			want:        `{"name":"\u003cb\u003e"}`,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "indented",
+			render:      &IndentedJSON{Data: map[string]any{"a": 1}},
+			want:        "{\n    \"a\": 1\n}",
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "secure array",
+			render:      &SecureJSON{Prefix: "while(1);", Data: []int{1, 2}},
+			want:        `while(1);[1,2]`,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "secure object",
+			render:      &SecureJSON{Prefix: "while(1);", Data: map[string]int{"a": 1}},
+			want:        `{"a":1}`,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "jsonp",
+			render:      &JSONP{Callback: "app.cb", Data: map[string]int{"a": 1}},
+			want:        `/**/app.cb({"a":1});`,
+			contentType: "application/javascript; charset=utf-8",
+		},
+		{
+			name:        "jsonp invalid callback",
+			render:      &JSONP{Callback: "alert(1)//", Data: 1},
+			contentType: "application/javascript; charset=utf-8",
+			wantErr:     ErrInvalidCallback,
+		},
+		{
+			name:        "ascii",
+			render:      &AsciiJSON{Data: "张三😀"},
+			want:        `"\u5f20\u4e09\ud83d\ude00"`,
+			contentType: "application/json",
+		},
+		{
+			name:        "pure",
+			render:      &PureJSON{Data: "<b>"},
+			want:        "\"<b>\"\n",
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "stream slice",
+			render:      &StreamJSON{Data: []string{"a", "b"}},
+			want:        "[\"a\"\n,\"b\"\n]",
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "stream chan",
+			render:      &StreamJSON{Data: ch},
+			want:        "[1\n,2\n]",
+			contentType: "application/json; charset=utf-8",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			w := httptest.NewRecorder()
+			err := tc.render.Render(w)
+			assert.Equal(tt, tc.contentType, w.Header().Get("Content-Type"))
+			if tc.wantErr != nil {
+				assert.ErrorIs(tt, err, tc.wantErr)
+				return
+			}
+			assert.NoError(tt, err)
+			assert.Equal(tt, tc.want, w.Body.String())
+		})
+	}
+}
+
+func TestMsgPackContentType(t *testing.T) {
+	w := httptest.NewRecorder()
+	assert.NoError(t, (&MsgPack{Data: 1}).Render(w))
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+}
+
+func TestSSERender(t *testing.T) {
+	testCase := []struct {
+		name   string
+		render *SSE
+		want   string
+	}{
+		{
+			name:   "event",
+			render: &SSE{Id: "1", Event: "progress", Data: "50%"},
+			want:   "id: 1\nevent: progress\ndata: 50%\n\n",
+		},
+		{
+			name:   "multiline",
+			render: &SSE{Data: "a\nb"},
+			want:   "data: a\ndata: b\n\n",
+		},
+		{
+			name:   "json",
+			render: &SSE{Event: "job", Retry: 3000, Data: map[string]int{"done": 1}},
+			want:   "event: job\nretry: 3000\ndata: {\"done\":1}\n\n",
+		},
+		{
+			name:   "carriage return",
+			render: &SSE{Comment: "a\rb", Data: "c\r\nd\re\n"},
+			want:   ": a\n: b\ndata: c\ndata: d\ndata: e\ndata: \n\n",
+		},
+		{
+			name:   "keep alive",
+			render: &SSE{Comment: "keep-alive"},
+			want:   ": keep-alive\n\n",
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			w := httptest.NewRecorder()
+			assert.NoError(tt, tc.render.Render(w))
+			assert.Equal(tt, tc.want, w.Body.String())
+			assert.Equal(tt, "text/event-stream", w.Header().Get("Content-Type"))
+			assert.True(tt, w.Flushed)
+		})
+	}
+}
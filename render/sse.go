@@ -0,0 +1,88 @@
+package render
+
+import (
+	"fmt"
+	"github.com/dalefeng/fesgo/codec"
+	"net/http"
+	"strings"
+)
+
+// SSE Server-Sent Events 的一条消息, 写入后立即 Flush
+type SSE struct {
+	Id      string
+	Event   string
+	Retry   uint // 客户端重连间隔, 毫秒
+	Comment string
+	Data    any
+}
+
+var sseReplacer = strings.NewReplacer("\n", "\\n", "\r", "\\r")
+
+// sseNewline 统一换行符, \r\n、\r 和 \n 都是 SSE 的换行符
+var sseNewline = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// sseLines 按行拆分
+func sseLines(s string) []string {
+	return strings.Split(sseNewline.Replace(s), "\n")
+}
+
+func (s *SSE) Render(w http.ResponseWriter) error {
+	s.WriterContentType(w)
+	var sb strings.Builder
+	if s.Comment != "" {
+		for _, line := range sseLines(s.Comment) {
+			sb.WriteString(": " + line + "\n")
+		}
+	}
+	if s.Id != "" {
+		sb.WriteString("id: " + sseReplacer.Replace(s.Id) + "\n")
+	}
+	if s.Event != "" {
+		sb.WriteString("event: " + sseReplacer.Replace(s.Event) + "\n")
+	}
+	if s.Retry > 0 {
+		sb.WriteString(fmt.Sprintf("retry: %d\n", s.Retry))
+	}
+	if s.Data != nil {
+		data, err := s.data()
+		if err != nil {
+			return err
+		}
+		// 多行数据每行都要加 data 前缀
+		for _, line := range sseLines(data) {
+			sb.WriteString("data: " + line + "\n")
+		}
+	}
+	sb.WriteString("\n")
+	_, err := w.Write([]byte(sb.String()))
+	if err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (s *SSE) data() (string, error) {
+	switch d := s.Data.(type) {
+	case string:
+		return d, nil
+	case []byte:
+		return string(d), nil
+	case fmt.Stringer:
+		return d.String(), nil
+	default:
+		jsonData, err := codec.JSON.Marshal(d)
+		return string(jsonData), err
+	}
+}
+
+func (s *SSE) WriterContentType(w http.ResponseWriter) {
+	header := w.Header()
+	writerContentType(w, "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 关闭 nginx 的响应缓冲
+	header.Set("X-Accel-Buffering", "no")
+}
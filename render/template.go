@@ -0,0 +1,248 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TemplateManager 从多个目录或 fs.FS (例如 embed.FS) 加载模板
+// LayoutDir 和 PartialDir 下的模板是公共模板, 其他模板是页面, 每个页面和公共模板单独编译,
+// 页面通过 {{define}} 覆盖布局中的 {{block}}, 不同页面之间的同名 block 互不影响
+type TemplateManager struct {
+	Extension  string // 模板文件扩展名
+	LayoutDir  string // 布局模板目录, 相对模板根目录
+	PartialDir string // 公共片段目录, 相对模板根目录
+	FuncMap    template.FuncMap
+	AutoReload bool // 文件变化时重新编译, 关闭时只编译一次
+
+	sources   []fs.FS
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	stamp     string
+}
+
+type templateFile struct {
+	fsys fs.FS
+	name string
+}
+
+func NewTemplateManager(funcMap template.FuncMap) *TemplateManager {
+	return &TemplateManager{
+		Extension:  ".html",
+		LayoutDir:  "layouts",
+		PartialDir: "partials",
+		FuncMap:    funcMap,
+	}
+}
+
+// AddDir 添加模板目录, 多个目录中存在同名模板时先添加的优先
+func (m *TemplateManager) AddDir(dirs ...string) *TemplateManager {
+	for _, dir := range dirs {
+		m.sources = append(m.sources, os.DirFS(dir))
+	}
+	return m
+}
+
+// AddFS 添加模板文件系统, embed.FS 可以先通过 fs.Sub 去掉目录前缀
+func (m *TemplateManager) AddFS(fsys ...fs.FS) *TemplateManager {
+	m.sources = append(m.sources, fsys...)
+	return m
+}
+
+// Load 编译所有模板
+func (m *TemplateManager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load()
+}
+
+func (m *TemplateManager) load() error {
+	files, stamp, err := m.files()
+	if err != nil {
+		return err
+	}
+	templates, err := m.compile(files)
+	if err != nil {
+		return err
+	}
+	m.templates = templates
+	m.stamp = stamp
+	return nil
+}
+
+// files 遍历所有来源中的模板文件, stamp 是文件列表和修改时间的摘要, 用于判断是否需要重新编译
+func (m *TemplateManager) files() ([]templateFile, string, error) {
+	files := make([]templateFile, 0)
+	seen := make(map[string]bool)
+	var sb strings.Builder
+	for _, fsys := range m.sources {
+		err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(name, m.Extension) || seen[name] {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			seen[name] = true
+			files = append(files, templateFile{fsys: fsys, name: name})
+			fmt.Fprintf(&sb, "%s:%d:%d;", name, info.ModTime().UnixNano(), info.Size())
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	return files, sb.String(), nil
+}
+
+func (m *TemplateManager) isShared(name string) bool {
+	for _, dir := range []string{m.LayoutDir, m.PartialDir} {
+		if dir != "" && strings.HasPrefix(name, strings.Trim(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *TemplateManager) compile(files []templateFile) (map[string]*template.Template, error) {
+	base := template.New("").Funcs(m.funcs(nil))
+	pages := make([]templateFile, 0, len(files))
+	for _, file := range files {
+		if !m.isShared(file.name) {
+			pages = append(pages, file)
+			continue
+		}
+		if err := parseFile(base, file); err != nil {
+			return nil, err
+		}
+	}
+	templates := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		if m.isShared(file.name) {
+			templates[file.name] = base
+		}
+	}
+	base.Funcs(m.funcs(base))
+
+	for _, page := range pages {
+		t, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err = parseFile(t, page); err != nil {
+			return nil, err
+		}
+		t.Funcs(m.funcs(t))
+		templates[page.name] = t
+	}
+	return templates, nil
+}
+
+func parseFile(t *template.Template, file templateFile) error {
+	content, err := fs.ReadFile(file.fsys, file.name)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(file.name).Parse(string(content))
+	return err
+}
+
+// funcs 在用户函数的基础上增加 partial 和 dict, partial 在当前模板集合中执行指定模板
+func (m *TemplateManager) funcs(t *template.Template) template.FuncMap {
+	funcMap := template.FuncMap{
+		"partial": func(name string, data ...any) (template.HTML, error) {
+			if t == nil {
+				return "", errors.New("template not loaded")
+			}
+			var d any
+			if len(data) > 0 {
+				d = data[0]
+			}
+			var buf bytes.Buffer
+			if err := t.ExecuteTemplate(&buf, name, d); err != nil {
+				return "", err
+			}
+			return template.HTML(buf.String()), nil
+		},
+		"dict": dict,
+	}
+	for k, v := range m.FuncMap {
+		funcMap[k] = v
+	}
+	return funcMap
+}
+
+// dict 将键值对转换为 map, 用于给 partial 传递多个参数
+func dict(values ...any) (map[string]any, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments")
+	}
+	m := make(map[string]any, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not string", values[i])
+		}
+		m[key] = values[i+1]
+	}
+	return m, nil
+}
+
+// Lookup 查找模板, AutoReload 开启时文件变化后重新编译
+func (m *TemplateManager) Lookup(name string) (*template.Template, error) {
+	if m.AutoReload {
+		if err := m.reload(); err != nil {
+			return nil, err
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("html/template: %q is undefined", name)
+	}
+	return t, nil
+}
+
+func (m *TemplateManager) reload() error {
+	_, stamp, err := m.files()
+	if err != nil {
+		return err
+	}
+	m.mu.RLock()
+	changed := m.templates == nil || stamp != m.stamp
+	m.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load()
+}
+
+func (m *TemplateManager) ExecuteTemplate(w io.Writer, name string, data any) error {
+	t, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+func (m *TemplateManager) Instance(name string, data any) Render {
+	return &HTML{Name: name, Data: data, Manager: m, IsTemplate: true}
+}
@@ -0,0 +1,81 @@
+package render
+
+import (
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type templateUser struct {
+	Name string
+}
+
+func TestTemplateManager(t *testing.T) {
+	m := NewTemplateManager(template.FuncMap{"upper": strings.ToUpper}).AddDir("testdata/templates")
+	assert.NoError(t, m.Load())
+
+	w := httptest.NewRecorder()
+	err := m.Instance("users/index.html", []templateUser{{Name: "feng"}}).Render(w)
+	assert.NoError(t, err)
+	assert.Equal(t, "<title>users</title>\n<main><ul><li>feng</li>\n</ul></main>", strings.TrimSpace(w.Body.String()))
+
+	// 不同页面的 block 互不影响
+	w = httptest.NewRecorder()
+	err = m.Instance("index.html", "home").Render(w)
+	assert.NoError(t, err)
+	assert.Equal(t, "<title>fesgo</title>\n<main>HOME</main>", strings.TrimSpace(w.Body.String()))
+
+	// 渲染出错时不写入任何内容
+	w = httptest.NewRecorder()
+	err = m.Instance("users/index.html", 1).Render(w)
+	assert.Error(t, err)
+	assert.Equal(t, 0, w.Body.Len())
+	assert.Equal(t, "", w.Header().Get("Content-Type"))
+}
+
+func TestTemplateManagerFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`[{{block "content" .}}{{end}}]`)},
+		"page.html":         {Data: []byte(`{{template "layouts/base.html" .}}{{define "content"}}fs {{.}}{{end}}`)},
+	}
+	override := fstest.MapFS{
+		"page.html": {Data: []byte(`{{template "layouts/base.html" .}}{{define "content"}}override {{.}}{{end}}`)},
+	}
+	m := NewTemplateManager(nil).AddFS(override, fsys)
+	assert.NoError(t, m.Load())
+	w := httptest.NewRecorder()
+	assert.NoError(t, m.Instance("page.html", 1).Render(w))
+	assert.Equal(t, "[override 1]", w.Body.String())
+}
+
+func TestTemplateManagerReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "page.html")
+	assert.NoError(t, os.WriteFile(file, []byte("v1"), 0644))
+
+	m := NewTemplateManager(nil).AddDir(dir)
+	m.AutoReload = true
+	assert.NoError(t, m.Load())
+	w := httptest.NewRecorder()
+	assert.NoError(t, m.ExecuteTemplate(w, "page.html", nil))
+	assert.Equal(t, "v1", w.Body.String())
+
+	assert.NoError(t, os.WriteFile(file, []byte("v2 changed"), 0644))
+	os.Chtimes(file, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	w = httptest.NewRecorder()
+	assert.NoError(t, m.ExecuteTemplate(w, "page.html", nil))
+	assert.Equal(t, "v2 changed", w.Body.String())
+
+	// 关闭自动重载后使用缓存
+	m.AutoReload = false
+	assert.NoError(t, os.WriteFile(file, []byte("v3"), 0644))
+	w = httptest.NewRecorder()
+	assert.NoError(t, m.ExecuteTemplate(w, "page.html", nil))
+	assert.Equal(t, "v2 changed", w.Body.String())
+}
@@ -0,0 +1,44 @@
+package render
+
+import (
+	"bytes"
+	"net/http"
+	"text/template"
+)
+
+// TextProduction 使用 text/template, 不做 HTML 转义, 适合邮件和纯文本
+type TextProduction struct {
+	Template    *template.Template
+	ContentType string // 为空时使用 text/plain
+}
+
+func (r TextProduction) Instance(name string, data any) Render {
+	return &Text{Name: name, Data: data, Template: r.Template, ContentType: r.ContentType}
+}
+
+type Text struct {
+	Name        string
+	Data        any
+	Template    *template.Template
+	ContentType string
+}
+
+func (t *Text) Render(w http.ResponseWriter) error {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+	if err := t.Template.ExecuteTemplate(buf, t.Name, t.Data); err != nil {
+		return err
+	}
+	t.WriterContentType(w)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (t *Text) WriterContentType(w http.ResponseWriter) {
+	if t.ContentType != "" {
+		writerContentType(w, t.ContentType)
+		return
+	}
+	writerContentType(w, "text/plain; charset=utf-8")
+}
@@ -0,0 +1,25 @@
+package render
+
+import (
+	"net/http"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+type TOML struct {
+	Data any
+}
+
+func (t *TOML) Render(w http.ResponseWriter) error {
+	t.WriterContentType(w)
+	tomlData, err := toml.Marshal(t.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(tomlData)
+	return err
+}
+
+func (t *TOML) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/toml; charset=utf-8")
+}
@@ -0,0 +1,25 @@
+package render
+
+import (
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+type YAML struct {
+	Data any
+}
+
+func (y *YAML) Render(w http.ResponseWriter) error {
+	y.WriterContentType(w)
+	yamlData, err := yaml.Marshal(y.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(yamlData)
+	return err
+}
+
+func (y *YAML) WriterContentType(w http.ResponseWriter) {
+	writerContentType(w, "application/yaml; charset=utf-8")
+}
@@ -0,0 +1,78 @@
+package fesgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	fesLog "github.com/dalefeng/fesgo/logger"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+	requestIDKey    = "request_id"
+	maxRequestIDLen = 128
+)
+
+type RequestIDConfig struct {
+	Header    string        // 默认 X-Request-ID
+	Generator func() string // 默认 32 位十六进制随机数
+}
+
+// RequestIDWithConfig 从请求头读取请求 ID, 没有或不合法时生成新的, 保存到 Context 中并返回给客户端,
+// 同时添加到本次请求的日志字段 request_id 中
+func RequestIDWithConfig(config RequestIDConfig, next HandlerFunc) HandlerFunc {
+	header := config.Header
+	if header == "" {
+		header = HeaderRequestID
+	}
+	generator := config.Generator
+	if generator == nil {
+		generator = newRequestID
+	}
+	return func(c *Context) {
+		id := c.R.Header.Get(header)
+		if !validRequestID(id) {
+			id = generator()
+		}
+		c.Set(requestIDKey, id)
+		c.W.Header().Set(header, id)
+		if c.Logger != nil {
+			c.Logger = c.Logger.With(fesLog.Fields{requestIDKey: id})
+		}
+		next(c)
+	}
+}
+
+func RequestID(next HandlerFunc) HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{}, next)
+}
+
+// RequestID 本次请求的 ID, 没有使用 RequestID 中间件时为空
+func (c *Context) RequestID() string {
+	if id, ok := c.Get(requestIDKey); ok {
+		if s, ok := id.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID 只接受可见的 ASCII 字符, 避免客户端在日志中注入内容
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
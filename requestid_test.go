@@ -0,0 +1,58 @@
+package fesgo
+
+import (
+	"bytes"
+	fesLog "github.com/dalefeng/fesgo/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	engine := NewEngine()
+	out := &bytes.Buffer{}
+	engine.Logger = &fesLog.Logger{
+		Out:       []*fesLog.LoggerWriter{{Level: -1, W: out}},
+		Formatter: &fesLog.JsonFormatter{},
+	}
+	g := engine.Group("api")
+	g.Use(RequestID)
+	g.Get("/hello", func(ctx *Context) {
+		ctx.Logger.Info("hello")
+		ctx.String(http.StatusOK, ctx.RequestID())
+	})
+
+	testCase := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"from header", "abc-123", true},
+		{"generate", "", false},
+		{"invalid", "abc\n123", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			out.Reset()
+			r := httptest.NewRequest(http.MethodGet, "/api/hello", nil)
+			if tc.header != "" {
+				r.Header.Set(HeaderRequestID, tc.header)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			id := w.Header().Get(HeaderRequestID)
+			if tc.keep {
+				assert.Equal(t, tc.header, id)
+			} else {
+				assert.Len(t, id, 32)
+			}
+			assert.Equal(t, id, w.Body.String())
+			assert.Contains(t, out.String(), `"request_id":"`+id+`"`)
+		})
+	}
+	// 不修改 Engine 的 Logger
+	assert.Nil(t, engine.Logger.Fields)
+}
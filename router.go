@@ -22,6 +22,7 @@ func (r *router) Group(name string) *routerGroup {
 		middlewareFuncMap: make(map[string]map[string][]MiddlewareFunc),
 		handleMethodMap:   make(map[string][]string),
 		treeNode:          &treeNode{name: "/", children: make([]*treeNode, 0)},
+		engine:            r.Engine,
 	}
 	group.Use(r.Engine.middles...)
 	r.routerGroups = append(r.routerGroups, group)
@@ -36,6 +37,24 @@ type routerGroup struct {
 	handleMethodMap map[string][]string
 	treeNode        *treeNode
 	middleware      []MiddlewareFunc // 组级中间件
+	engine          *Engine
+}
+
+type Route struct {
+	Method string
+	Path   string // 包含分组前缀的完整路径
+	name   string
+	engine *Engine
+}
+
+// Name 给路由命名, 用于 Engine.URL 和 Context.RedirectToRoute
+func (r *Route) Name(name string) *Route {
+	if _, ok := r.engine.namedRoutes[name]; ok {
+		panic("路由名称已存在: " + name)
+	}
+	r.name = name
+	r.engine.namedRoutes[name] = r
+	return r
 }
 
 func (r *routerGroup) Use(middlewareFunc ...MiddlewareFunc) {
@@ -46,23 +65,35 @@ func (r *routerGroup) MethodHandle(ctx *Context, name, method string, h HandlerF
 	if h == nil {
 		h = EmptyHandlerFunc
 	}
-	// 组中间件
-	if r.middleware != nil {
-		for _, middlewareFunc := range r.middleware {
-			h = middlewareFunc(h)
-		}
-	}
-	// 路由级别
-	middlewareFunc, ok := r.middlewareFuncMap[name][method]
-	if ok {
-		for _, mFunc := range middlewareFunc {
-			h = mFunc(h)
-		}
+	// 默认路由级中间件在外层, 先于组中间件执行
+	// Engine.GroupMiddlewareFirst 为 true 时组中间件在外层, 路由级中间件可以使用组中间件认证的结果
+	if r.engine != nil && r.engine.GroupMiddlewareFirst {
+		h = r.wrapRoute(name, method, h)
+		h = r.wrapGroup(h)
+	} else {
+		h = r.wrapGroup(h)
+		h = r.wrapRoute(name, method, h)
 	}
 	h(ctx)
 }
 
-func (r *routerGroup) handle(name, method string, handleFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
+// wrapGroup 组中间件, 后添加的在外层
+func (r *routerGroup) wrapGroup(h HandlerFunc) HandlerFunc {
+	for _, middlewareFunc := range r.middleware {
+		h = middlewareFunc(h)
+	}
+	return h
+}
+
+// wrapRoute 路由级别中间件, 后添加的在外层
+func (r *routerGroup) wrapRoute(name, method string, h HandlerFunc) HandlerFunc {
+	for _, mFunc := range r.middlewareFuncMap[name][method] {
+		h = mFunc(h)
+	}
+	return h
+}
+
+func (r *routerGroup) handle(name, method string, handleFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
 	_, ok := r.handleFuncMap[name]
 	if !ok {
 		r.handleFuncMap[name] = make(map[string]HandlerFunc)
@@ -76,26 +107,27 @@ func (r *routerGroup) handle(name, method string, handleFunc HandlerFunc, middle
 	r.middlewareFuncMap[name][method] = append(r.middlewareFuncMap[name][method], middlewareFunc...)
 
 	r.treeNode.Put(name)
+	return &Route{Method: method, Path: "/" + r.name + name, engine: r.engine}
 }
 
-func (r *routerGroup) Any(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, ANY, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Any(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, ANY, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Get(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodGet, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Get(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodGet, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Post(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPost, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Post(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPost, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Put(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPut, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Put(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPut, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Delete(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodDelete, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Delete(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodDelete, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Patch(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPatch, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Patch(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPatch, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Head(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodHead, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Head(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodHead, handlerFunc, middlewareFunc...)
 }
@@ -0,0 +1,123 @@
+package fesgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newRedirectEngine() *Engine {
+	engine := NewEngine()
+	engine.RedirectFixedPath = true
+	user := engine.Group("api")
+	user.Get("/users", func(ctx *Context) {
+		ctx.String(http.StatusOK, "users")
+	})
+	user.Post("/Orders/:id", func(ctx *Context) {
+		ctx.String(http.StatusOK, "order")
+	})
+	user.Get("/users/:id/detail", func(ctx *Context) {
+		ctx.String(http.StatusOK, "detail")
+	}).Name("user.detail")
+	admin := engine.Group("admin")
+	admin.Get("/dashboard/", func(ctx *Context) {
+		ctx.String(http.StatusOK, "dashboard")
+	})
+	admin.Get("/go", func(ctx *Context) {
+		ctx.RedirectToRoute("user.detail", map[string]string{"id": "1 2", "tab": "info"})
+	})
+	return engine
+}
+
+func TestRedirectPath(t *testing.T) {
+	testCase := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		location string
+		body     string
+	}{
+		{name: "match", method: http.MethodGet, path: "/api/users", wantCode: http.StatusOK, body: "users"},
+		{name: "second group", method: http.MethodGet, path: "/admin/dashboard/", wantCode: http.StatusOK, body: "dashboard"},
+		{name: "trailing slash", method: http.MethodGet, path: "/api/users/", wantCode: http.StatusMovedPermanently, location: "/api/users"},
+		{name: "add trailing slash", method: http.MethodGet, path: "/admin/dashboard", wantCode: http.StatusMovedPermanently, location: "/admin/dashboard/"},
+		{name: "duplicate slash", method: http.MethodGet, path: "//api//users", wantCode: http.StatusMovedPermanently, location: "/api/users"},
+		{name: "dot dot", method: http.MethodGet, path: "/api/orders/../users?page=1", wantCode: http.StatusMovedPermanently, location: "/api/users?page=1"},
+		{name: "case insensitive post", method: http.MethodPost, path: "/API/orders/Ab", wantCode: http.StatusPermanentRedirect, location: "/api/Orders/Ab"},
+		{name: "not found", method: http.MethodGet, path: "/api/none", wantCode: http.StatusNotFound},
+		{name: "prefix boundary", method: http.MethodGet, path: "/apiusers", wantCode: http.StatusNotFound},
+		{name: "redirect to route", method: http.MethodGet, path: "/admin/go", wantCode: http.StatusFound, location: "/api/users/1%202/detail?tab=info"},
+	}
+	engine := newRedirectEngine()
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			assert.Equal(tt, tc.wantCode, w.Code)
+			assert.Equal(tt, tc.location, w.Header().Get("Location"))
+			if tc.body != "" {
+				assert.Equal(tt, tc.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var order []string
+	record := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				order = append(order, name)
+				next(ctx)
+			}
+		}
+	}
+	engine := NewEngine()
+	g := engine.Group("api")
+	g.Use(record("group1"), record("group2"))
+	g.Get("/users", func(ctx *Context) {
+		order = append(order, "handler")
+	}, record("route1"), record("route2"))
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	// 默认路由级中间件在外层先执行, 同一级别后添加的先执行
+	assert.Equal(t, []string{"route2", "route1", "group2", "group1", "handler"}, order)
+
+	order = nil
+	engine.GroupMiddlewareFirst = true
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	assert.Equal(t, []string{"group2", "group1", "route2", "route1", "handler"}, order)
+}
+
+func TestRedirectPathOpenRedirect(t *testing.T) {
+	engine := NewEngine()
+	g := engine.Group("")
+	g.Get("/:name", func(ctx *Context) {
+		ctx.String(http.StatusOK, "name")
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.URL.Path = "//evil.com/"
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	// 不能重定向到 //evil.com
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/evil.com", w.Header().Get("Location"))
+}
+
+func TestRemoveExtraSlash(t *testing.T) {
+	engine := newRedirectEngine()
+	engine.RemoveExtraSlash = true
+	r := httptest.NewRequest(http.MethodGet, "/api//users", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "users", w.Body.String())
+
+	_, err := engine.URL("user.detail", nil)
+	assert.Error(t, err)
+	_, err = engine.URL("none", nil)
+	assert.Error(t, err)
+}
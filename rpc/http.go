@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/dalefeng/fesgo"
 	"io"
 	"net/http"
 	"net/url"
@@ -14,6 +15,7 @@ import (
 
 type FesHttpClient struct {
 	client http.Client
+	ctx    *fesgo.Context
 }
 
 func NewFesHttpClient() *FesHttpClient {
@@ -33,6 +35,14 @@ func NewFesHttpClient() *FesHttpClient {
 		client: client,
 	}
 }
+
+// WithContext 返回使用 ctx 发起请求的客户端, 请求跟随 ctx 取消, 并传递请求 ID
+func (c *FesHttpClient) WithContext(ctx *fesgo.Context) *FesHttpClient {
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
 func (c *FesHttpClient) Response(req *http.Request) ([]byte, error) {
 	return c.responseHandle(req)
 }
@@ -78,6 +88,12 @@ func (c *FesHttpClient) JsonRequest(method, url string, args map[string]any) (*h
 }
 
 func (c *FesHttpClient) responseHandle(request *http.Request) ([]byte, error) {
+	if c.ctx != nil {
+		request = request.WithContext(c.ctx.R.Context())
+		if id := c.ctx.RequestID(); id != "" && request.Header.Get(fesgo.HeaderRequestID) == "" {
+			request.Header.Set(fesgo.HeaderRequestID, id)
+		}
+	}
 	resp, err := c.client.Do(request)
 	if err != nil {
 		return nil, err
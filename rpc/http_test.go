@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"github.com/dalefeng/fesgo"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithContextRequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(fesgo.HeaderRequestID)))
+	}))
+	defer server.Close()
+
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(fesgo.RequestID)
+	client := NewFesHttpClient()
+	g.Get("/proxy", func(ctx *fesgo.Context) {
+		body, err := client.WithContext(ctx).Get(server.URL, nil)
+		if err != nil {
+			ctx.Abort(err)
+			return
+		}
+		ctx.String(http.StatusOK, string(body))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/proxy", nil)
+	r.Header.Set(fesgo.HeaderRequestID, "req-1")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	assert.Equal(t, "req-1", w.Body.String())
+
+	// 没有传入 Context 时不添加
+	body, err := client.Get(server.URL, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, body)
+}
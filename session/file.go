@@ -0,0 +1,101 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStore 每个会话保存为 Dir 下的一个文件, 文件开头 8 字节为过期时间
+type FileStore struct {
+	Dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if !validID(id) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.Dir, "sess_"+id), nil
+}
+
+func (s *FileStore) Get(id string) ([]byte, error) {
+	p, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < 8 {
+		os.Remove(p)
+		return nil, ErrNotFound
+	}
+	expiry := int64(binary.BigEndian.Uint64(content[:8]))
+	if time.Now().UnixNano() > expiry {
+		os.Remove(p)
+		return nil, ErrNotFound
+	}
+	return content[8:], nil
+}
+
+// Set 先写入临时文件再重命名, 避免并发读取到写了一半的文件
+func (s *FileStore) Set(id string, data []byte, ttl time.Duration) error {
+	p, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	content := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(content[:8], uint64(time.Now().Add(ttl).UnixNano()))
+	copy(content[8:], data)
+
+	f, err := os.CreateTemp(s.Dir, ".tmp_")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *FileStore) Delete(id string) error {
+	p, err := s.path(id)
+	if err != nil {
+		return nil
+	}
+	if err = os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// GC 删除过期的会话文件, 需要定期调用
+func (s *FileStore) GC() error {
+	matches, err := filepath.Glob(filepath.Join(s.Dir, "sess_*"))
+	if err != nil {
+		return err
+	}
+	for _, p := range matches {
+		s.Get(filepath.Base(p)[len("sess_"):])
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// gcInterval 清理过期会话的间隔
+const gcInterval = time.Minute
+
+type memoryItem struct {
+	data   []byte
+	expiry time.Time
+}
+
+// MemoryStore 内存存储, 进程重启后会话丢失, 适合单机部署
+type MemoryStore struct {
+	mu     sync.Mutex
+	items  map[string]memoryItem
+	lastGC time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem), lastGC: time.Now()}
+}
+
+func (s *MemoryStore) Get(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if time.Now().After(item.expiry) {
+		delete(s.items, id)
+		return nil, ErrNotFound
+	}
+	return item.data, nil
+}
+
+func (s *MemoryStore) Set(id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.items[id] = memoryItem{data: data, expiry: now.Add(ttl)}
+	// 写入时顺便清理过期的会话, 不需要单独的协程
+	if now.Sub(s.lastGC) > gcInterval {
+		for k, item := range s.items {
+			if now.After(item.expiry) {
+				delete(s.items, k)
+			}
+		}
+		s.lastGC = now
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
+
+// Len 当前保存的会话数量, 包括还没有清理的过期会话
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
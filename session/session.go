@@ -0,0 +1,293 @@
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"github.com/dalefeng/fesgo"
+	"net/http"
+	"time"
+)
+
+// contextKey 会话保存在 Context.Keys 中的 key
+const contextKey = "fes_session"
+
+type Options struct {
+	CookieName string
+	Path       string
+	Domain     string
+	MaxAge     int // cookie 有效期, 单位秒, 0 表示浏览器关闭时失效
+	Secure     bool
+	HttpOnly   bool
+	SameSite   http.SameSite
+
+	IdleTimeout     time.Duration // 多久没有访问后失效, 默认 30 分钟
+	AbsoluteTimeout time.Duration // 从创建或 Regenerate 开始的最长有效期, 0 表示不限制
+}
+
+// Manager 会话中间件, cookie 中只保存会话 id
+// Engine.SecureCookie 不为空时 cookie 会被签名
+type Manager struct {
+	Store   Store
+	Options Options
+}
+
+func New(store Store, options Options) *Manager {
+	if options.CookieName == "" {
+		options.CookieName = "fes_session"
+	}
+	if options.Path == "" {
+		options.Path = "/"
+	}
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = 30 * time.Minute
+	}
+	return &Manager{Store: store, Options: options}
+}
+
+// record 存储中保存的会话数据, 使用 gob 编码, 自定义类型需要先 gob.Register
+type record struct {
+	Values    map[string]any
+	Flashes   map[string][]any
+	CreatedAt int64
+}
+
+type Session struct {
+	manager *Manager
+	ctx     *fesgo.Context
+
+	id        string
+	oldID     string // Regenerate 之前的 id, 保存时删除
+	values    map[string]any
+	flashes   map[string][]any
+	createdAt time.Time
+
+	loaded      bool // 从存储中加载
+	cookieValid bool // 客户端的 cookie 中是当前的 id
+}
+
+// Sessions 请求前加载会话, 请求结束后保存
+func (m *Manager) Sessions(next fesgo.HandlerFunc) fesgo.HandlerFunc {
+	return func(ctx *fesgo.Context) {
+		s := m.load(ctx)
+		ctx.Set(contextKey, s)
+		next(ctx)
+		if err := s.save(); err != nil && ctx.Logger != nil {
+			ctx.Logger.Error(err)
+		}
+	}
+}
+
+// Default 获取当前请求的会话, 没有使用 Sessions 中间件时返回 nil
+func Default(ctx *fesgo.Context) *Session {
+	s, ok := ctx.Get(contextKey)
+	if !ok {
+		return nil
+	}
+	return s.(*Session)
+}
+
+func (m *Manager) load(ctx *fesgo.Context) *Session {
+	s := &Session{
+		manager:   m,
+		ctx:       ctx,
+		values:    make(map[string]any),
+		flashes:   make(map[string][]any),
+		createdAt: time.Now(),
+	}
+	id, err := ctx.SignedCookie(m.Options.CookieName)
+	if errors.Is(err, fesgo.ErrSecureCookieNotSet) {
+		id, err = ctx.Cookie(m.Options.CookieName)
+	}
+	if err != nil || !validID(id) {
+		return s
+	}
+	data, err := m.Store.Get(id)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) && ctx.Logger != nil {
+			ctx.Logger.Error(err)
+		}
+		return s
+	}
+	rec := &record{}
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(rec); err != nil {
+		return s
+	}
+	createdAt := time.Unix(0, rec.CreatedAt)
+	if m.Options.AbsoluteTimeout > 0 && time.Since(createdAt) > m.Options.AbsoluteTimeout {
+		// 超过最长有效期, 丢弃旧会话, 使用新的 id
+		m.Store.Delete(id)
+		return s
+	}
+	s.id = id
+	s.createdAt = createdAt
+	s.loaded = true
+	s.cookieValid = true
+	if rec.Values != nil {
+		s.values = rec.Values
+	}
+	if rec.Flashes != nil {
+		s.flashes = rec.Flashes
+	}
+	return s
+}
+
+func (s *Session) ID() string {
+	return s.id
+}
+
+func (s *Session) IsNew() bool {
+	return !s.loaded
+}
+
+func (s *Session) Get(key string) any {
+	return s.values[key]
+}
+
+func (s *Session) Set(key string, value any) {
+	s.values[key] = value
+	s.touch()
+}
+
+func (s *Session) Delete(key string) {
+	if _, ok := s.values[key]; !ok {
+		return
+	}
+	delete(s.values, key)
+	s.touch()
+}
+
+// Clear 删除所有数据, 会话 id 不变
+func (s *Session) Clear() {
+	s.values = make(map[string]any)
+	s.flashes = make(map[string][]any)
+	s.touch()
+}
+
+// Flash 添加一次性消息, 在之后的请求中通过 Flashes 读取一次后删除
+func (s *Session) Flash(key string, value any) {
+	s.flashes[key] = append(s.flashes[key], value)
+	s.touch()
+}
+
+func (s *Session) Flashes(key string) []any {
+	values, ok := s.flashes[key]
+	if !ok {
+		return nil
+	}
+	delete(s.flashes, key)
+	s.touch()
+	return values
+}
+
+// Regenerate 更换会话 id 并保留数据, 登录等权限变化时调用, 防止会话固定攻击
+// 最长有效期从 Regenerate 开始重新计算
+func (s *Session) Regenerate() {
+	s.discardID()
+	s.createdAt = time.Now()
+	s.touch()
+}
+
+// Destroy 删除会话和 cookie, 退出登录时调用
+func (s *Session) Destroy() {
+	s.discardID()
+	s.values = make(map[string]any)
+	s.flashes = make(map[string][]any)
+	opts := s.manager.Options
+	s.ctx.SetCookieData(&http.Cookie{
+		Name:     opts.CookieName,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   -1,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+		SameSite: opts.SameSite,
+	})
+}
+
+// discardID 废弃当前 id, 存储中的旧会话在保存时删除
+func (s *Session) discardID() {
+	if s.loaded && s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = ""
+	s.cookieValid = false
+}
+
+// touch 标记会话被修改, 需要时生成 id 并写入 cookie, 响应头需要在写入响应体之前设置
+func (s *Session) touch() {
+	if s.id == "" {
+		s.id = newID()
+	}
+	if s.cookieValid {
+		return
+	}
+	s.cookieValid = true
+	opts := s.manager.Options
+	cookie := &http.Cookie{
+		Name:     opts.CookieName,
+		Value:    s.id,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   opts.MaxAge,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+		SameSite: opts.SameSite,
+	}
+	if err := s.ctx.SetSignedCookie(cookie); errors.Is(err, fesgo.ErrSecureCookieNotSet) {
+		s.ctx.SetCookieData(cookie)
+	}
+}
+
+// save 删除废弃的会话并保存当前会话, 没有修改的会话也重新保存, 用于刷新空闲过期时间
+func (s *Session) save() error {
+	store := s.manager.Store
+	if s.oldID != "" {
+		if err := store.Delete(s.oldID); err != nil {
+			return err
+		}
+	}
+	if s.id == "" {
+		return nil
+	}
+	ttl := s.manager.Options.IdleTimeout
+	if absolute := s.manager.Options.AbsoluteTimeout; absolute > 0 {
+		if remain := time.Until(s.createdAt.Add(absolute)); remain < ttl {
+			ttl = remain
+		}
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&record{
+		Values:    s.values,
+		Flashes:   s.flashes,
+		CreatedAt: s.createdAt.UnixNano(),
+	})
+	if err != nil {
+		return err
+	}
+	return store.Set(s.id, buf.Bytes(), ttl)
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// validID id 只包含 base64url 字符, 文件存储使用 id 作为文件名
+func validID(id string) bool {
+	if len(id) == 0 || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
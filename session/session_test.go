@@ -0,0 +1,164 @@
+package session
+
+import (
+	"github.com/dalefeng/fesgo"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// client 保存响应中的 cookie, 模拟浏览器
+type client struct {
+	engine  *fesgo.Engine
+	cookies map[string]*http.Cookie
+}
+
+func newClient(m *Manager) *client {
+	engine := fesgo.NewEngine()
+	g := engine.Group("api")
+	g.Use(m.Sessions)
+	g.Get("/set", func(ctx *fesgo.Context) {
+		Default(ctx).Set("user", ctx.GetQuery("user"))
+	})
+	g.Get("/get", func(ctx *fesgo.Context) {
+		user, _ := Default(ctx).Get("user").(string)
+		ctx.String(http.StatusOK, user)
+	})
+	g.Get("/login", func(ctx *fesgo.Context) {
+		s := Default(ctx)
+		s.Regenerate()
+		s.Set("role", "admin")
+	})
+	g.Get("/logout", func(ctx *fesgo.Context) {
+		Default(ctx).Destroy()
+	})
+	g.Get("/flash", func(ctx *fesgo.Context) {
+		Default(ctx).Flash("msg", "saved")
+	})
+	g.Get("/flashes", func(ctx *fesgo.Context) {
+		for _, f := range Default(ctx).Flashes("msg") {
+			ctx.String(http.StatusOK, f.(string))
+		}
+	})
+	return &client{engine: engine, cookies: make(map[string]*http.Cookie)}
+}
+
+func (c *client) get(path string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api"+path, nil)
+	for _, cookie := range c.cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	c.engine.ServeHTTP(w, r)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+			continue
+		}
+		c.cookies[cookie.Name] = cookie
+	}
+	return w
+}
+
+func (c *client) sessionID() string {
+	if cookie, ok := c.cookies["fes_session"]; ok {
+		return cookie.Value
+	}
+	return ""
+}
+
+func TestSession(t *testing.T) {
+	store := NewMemoryStore()
+	c := newClient(New(store, Options{}))
+
+	c.get("/get")
+	assert.Equal(t, "", c.sessionID(), "未修改的会话不写 cookie")
+	assert.Equal(t, 0, store.Len())
+
+	c.get("/set?user=feng")
+	id := c.sessionID()
+	assert.NotEmpty(t, id)
+	assert.Equal(t, "feng", c.get("/get").Body.String())
+
+	// 登录后更换 id, 旧 id 失效
+	c.get("/login")
+	assert.NotEqual(t, id, c.sessionID())
+	assert.Equal(t, "feng", c.get("/get").Body.String())
+	_, err := store.Get(id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, store.Len())
+
+	c.get("/flash")
+	assert.Equal(t, "saved", c.get("/flashes").Body.String())
+	assert.Equal(t, "", c.get("/flashes").Body.String())
+
+	c.get("/logout")
+	assert.Equal(t, "", c.sessionID())
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestSessionFixation(t *testing.T) {
+	store := NewMemoryStore()
+	c := newClient(New(store, Options{}))
+	// 伪造的 id 不会被使用
+	c.cookies["fes_session"] = &http.Cookie{Name: "fes_session", Value: "attacker"}
+	c.get("/set?user=feng")
+	assert.NotEqual(t, "attacker", c.sessionID())
+	_, err := store.Get("attacker")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSessionSigned(t *testing.T) {
+	store := NewMemoryStore()
+	c := newClient(New(store, Options{}))
+	c.engine.SecureCookie = fesgo.NewSecureCookie([]byte("0123456789abcdef0123456789abcdef"), nil)
+	c.get("/set?user=feng")
+	assert.Equal(t, "feng", c.get("/get").Body.String())
+
+	// 修改签名后的 cookie 会被当作新会话
+	c.cookies["fes_session"].Value = "x" + c.cookies["fes_session"].Value
+	assert.Equal(t, "", c.get("/get").Body.String())
+}
+
+func TestSessionExpiry(t *testing.T) {
+	testCase := []struct {
+		name    string
+		options Options
+	}{
+		{name: "idle", options: Options{IdleTimeout: 50 * time.Millisecond}},
+		{name: "absolute", options: Options{IdleTimeout: time.Hour, AbsoluteTimeout: 80 * time.Millisecond}},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newClient(New(NewMemoryStore(), tc.options))
+			c.get("/set?user=feng")
+			time.Sleep(30 * time.Millisecond)
+			assert.Equal(t, "feng", c.get("/get").Body.String())
+			time.Sleep(60 * time.Millisecond)
+			assert.Equal(t, "", c.get("/get").Body.String())
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+
+	_, err = store.Get("../../etc/passwd")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.Set("abc", []byte("data"), time.Hour))
+	data, err := store.Get("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("data"), data)
+
+	assert.NoError(t, store.Set("expired", []byte("data"), -time.Second))
+	_, err = store.Get("expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.Delete("abc"))
+	_, err = store.Get("abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,84 @@
+package session
+
+import (
+	"github.com/dalefeng/fesgo/orm"
+	"time"
+)
+
+// sessionRow 会话表结构, 以 mysql 为例:
+//
+//	create table sessions (
+//		id     varchar(64) not null primary key,
+//		data   blob        not null,
+//		expiry bigint      not null,
+//		index idx_expiry (expiry)
+//	)
+type sessionRow struct {
+	Id     string
+	Data   []byte
+	Expiry int64
+}
+
+// SQLStore 通过 orm.FesDB 保存会话, 表名会加上 FesDB.Prefix
+type SQLStore struct {
+	db    *orm.FesDB
+	Table string
+}
+
+func NewSQLStore(db *orm.FesDB, table string) *SQLStore {
+	if table == "" {
+		table = "sessions"
+	}
+	return &SQLStore{db: db, Table: table}
+}
+
+func (s *SQLStore) Get(id string) ([]byte, error) {
+	row := &sessionRow{}
+	err := s.db.NewSession(row).Table(s.Table).Where("id", id).SelectOne(row, "id", "data", "expiry")
+	if err != nil {
+		return nil, err
+	}
+	if row.Id == "" {
+		return nil, ErrNotFound
+	}
+	if time.Now().UnixNano() > row.Expiry {
+		s.Delete(id)
+		return nil, ErrNotFound
+	}
+	return row.Data, nil
+}
+
+// Set 先更新, 不存在时再插入, 不依赖各个数据库不同的 upsert 语法
+// 插入因为主键冲突失败时说明其他请求刚刚插入了同一个会话, 重新更新一次
+func (s *SQLStore) Set(id string, data []byte, ttl time.Duration) error {
+	expiry := time.Now().Add(ttl).UnixNano()
+	var insertErr error
+	for i := 0; i < 2; i++ {
+		row := &sessionRow{}
+		affected, err := s.db.NewSession(row).Exec("update "+s.db.Prefix+s.Table+" set data = ?, expiry = ? where id = ?", data, expiry, id)
+		if err != nil {
+			return err
+		}
+		if affected > 0 {
+			return nil
+		}
+		row = &sessionRow{Id: id, Data: data, Expiry: expiry}
+		if _, _, insertErr = s.db.NewSession(row).Table(s.Table).Insert(row); insertErr == nil {
+			return nil
+		}
+	}
+	return insertErr
+}
+
+func (s *SQLStore) Delete(id string) error {
+	row := &sessionRow{}
+	_, err := s.db.NewSession(row).Table(s.Table).Where("id", id).Delete(row)
+	return err
+}
+
+// GC 删除过期的会话, 需要定期调用
+func (s *SQLStore) GC() error {
+	row := &sessionRow{}
+	_, err := s.db.NewSession(row).Exec("delete from "+s.db.Prefix+s.Table+" where expiry < ?", time.Now().UnixNano())
+	return err
+}
@@ -0,0 +1,64 @@
+//go:build cgo
+
+// go-sqlite3 需要 cgo
+
+package session
+
+import (
+	"fmt"
+	"github.com/dalefeng/fesgo/orm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSQLStore(t *testing.T) *SQLStore {
+	db := orm.Open("sqlite3", filepath.Join(t.TempDir(), "session.db")+"?_busy_timeout=5000")
+	t.Cleanup(func() { db.Close() })
+	_, err := db.NewSession(&sessionRow{}).Exec("create table sessions (id varchar(64) not null primary key, data blob not null, expiry bigint not null)")
+	assert.NoError(t, err)
+	return NewSQLStore(db, "")
+}
+
+func TestSQLStore(t *testing.T) {
+	store := newSQLStore(t)
+
+	_, err := store.Get("abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.Set("abc", []byte("data"), time.Hour))
+	assert.NoError(t, store.Set("abc", []byte("data2"), time.Hour))
+	data, err := store.Get("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("data2"), data)
+
+	assert.NoError(t, store.Set("expired", []byte("data"), -time.Second))
+	_, err = store.Get("expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.Delete("abc"))
+	_, err = store.Get("abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLStoreConcurrentSet(t *testing.T) {
+	store := newSQLStore(t)
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = store.Set("abc", []byte(fmt.Sprint(i)), time.Hour)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	_, err := store.Get("abc")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,15 @@
+package session
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("session: not found")
+
+// Store 会话存储, data 为编码后的会话数据, ttl 到期后 Get 返回 ErrNotFound
+type Store interface {
+	Get(id string) ([]byte, error)
+	Set(id string, data []byte, ttl time.Duration) error
+	Delete(id string) error
+}
@@ -0,0 +1,82 @@
+package fesgo
+
+import (
+	"github.com/dalefeng/fesgo/render"
+	"io"
+	"net/http"
+)
+
+// Stream 循环调用 step 直到返回 false, 每次调用后 Flush
+// 客户端断开连接时停止并返回 true
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.R.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+		}
+		keepOpen := step(c.W)
+		c.flush()
+		if !keepOpen {
+			return false
+		}
+	}
+}
+
+// ClientGone 客户端是否已经断开连接
+func (c *Context) ClientGone() bool {
+	select {
+	case <-c.R.Context().Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Context) SSEvent(event string, data any) {
+	c.SSEventWithID("", event, data)
+}
+
+// SSEventWithID 带 id 的事件, 客户端重连时通过 Last-Event-ID 带回
+func (c *Context) SSEventWithID(id, event string, data any) {
+	err := c.Render(http.StatusOK, &render.SSE{Id: id, Event: event, Data: data})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+// SSERetry 设置客户端断线重连的间隔, 毫秒
+func (c *Context) SSERetry(retry uint) {
+	err := c.Render(http.StatusOK, &render.SSE{Retry: retry})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+// SSEKeepAlive 发送注释行保持连接, 避免代理因空闲断开
+func (c *Context) SSEKeepAlive() {
+	err := c.Render(http.StatusOK, &render.SSE{Comment: "keep-alive"})
+	if err != nil {
+		c.Abort(err)
+		return
+	}
+}
+
+// LastEventID 客户端重连时携带的最后一个事件 id, 用于断点续传
+func (c *Context) LastEventID() string {
+	id := c.R.Header.Get("Last-Event-ID")
+	if id == "" {
+		// 部分 EventSource polyfill 通过查询参数传递
+		id = c.GetQuery("lastEventId")
+	}
+	return id
+}
+
+func (c *Context) flush() {
+	if f, ok := c.W.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,41 @@
+package fesgo
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(reqCtx)
+	r.Header.Set("Last-Event-ID", "2")
+	w := httptest.NewRecorder()
+	ctx := &Context{R: r, W: w}
+
+	assert.Equal(t, "2", ctx.LastEventID())
+	count := 0
+	clientGone := ctx.Stream(func(w io.Writer) bool {
+		count++
+		ctx.SSEventWithID("3", "progress", count)
+		if count == 2 {
+			cancel()
+		}
+		return true
+	})
+	assert.True(t, clientGone)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "id: 3\nevent: progress\ndata: 1\n\nid: 3\nevent: progress\ndata: 2\n\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/", nil), W: w}
+	clientGone = ctx.Stream(func(w io.Writer) bool {
+		ctx.SSEKeepAlive()
+		return false
+	})
+	assert.False(t, clientGone)
+	assert.Equal(t, ": keep-alive\n\n", w.Body.String())
+}
@@ -0,0 +1,59 @@
+package fesgo
+
+import (
+	"github.com/dalefeng/fesgo/render"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+)
+
+type upperRender struct{}
+
+func (upperRender) Instance(name string, data any) render.Render {
+	return &render.String{Format: "%s:%v", Data: []any{name, data}}
+}
+
+func TestHTMLRender(t *testing.T) {
+	testCase := []struct {
+		name        string
+		htmlRender  render.HTMLRender
+		want        string
+		wantCode    int
+		contentType string
+	}{
+		{
+			name:        "custom",
+			htmlRender:  upperRender{},
+			want:        "mail:feng",
+			wantCode:    http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "text template",
+			htmlRender:  render.TextProduction{Template: template.Must(template.New("mail").Parse(`<p>{{.}}</p>`))},
+			want:        "<p>feng</p>",
+			wantCode:    http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "not set",
+			want:        "Internal Server Error",
+			wantCode:    http.StatusInternalServerError,
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			engine := NewEngine()
+			engine.HTMLRender = tc.htmlRender
+			w := httptest.NewRecorder()
+			ctx := &Context{engine: engine, W: w, R: httptest.NewRequest(http.MethodGet, "/", nil)}
+			ctx.Template("mail", "feng")
+			assert.Equal(tt, tc.wantCode, w.Code)
+			assert.Equal(tt, tc.want, w.Body.String())
+			assert.Equal(tt, tc.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
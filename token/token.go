@@ -188,12 +188,15 @@ func (j *JwtHandler) AuthInterceptor(next fesgo.HandlerFunc) fesgo.HandlerFunc {
 		token := ctx.R.Header.Get(j.Header)
 		if token == "" {
 			if j.SendCookie {
-				cookie, err := ctx.R.Cookie(JTWTOKENCOOKIE)
+				if j.CookieName == "" {
+					j.CookieName = JTWTOKENCOOKIE
+				}
+				cookie, err := ctx.Cookie(j.CookieName)
 				if err != nil {
 					j.AuthErrorHandle(ctx, err)
 					return
 				}
-				token = cookie.String()
+				token = cookie
 			}
 		}
 		if token == "" {
@@ -24,6 +24,9 @@ func (t *treeNode) Put(path string) {
 				isMatch = true
 				children = node.children
 				root = node
+				if index == len(strs)-1 {
+					node.isEnd = true
+				}
 				break
 			}
 		}
@@ -34,9 +37,10 @@ func (t *treeNode) Put(path string) {
 				isEnd = true
 			}
 			node := &treeNode{
-				name:     name,
-				children: make([]*treeNode, 0),
-				isEnd:    isEnd,
+				name:       name,
+				children:   make([]*treeNode, 0),
+				routerName: root.routerName + "/" + name,
+				isEnd:      isEnd,
 			}
 			root.children = append(root.children, node)
 			root = node
@@ -46,35 +50,79 @@ func (t *treeNode) Put(path string) {
 
 func (t *treeNode) Get(path string) *treeNode {
 	strs := strings.Split(path, "/")
-	routerName := ""
 	for index, name := range strs {
 		if index == 0 {
 			continue
 		}
-		children := t.children
-		isMatch := false
-		for _, node := range children {
-			// 找到结点就结束，继续找下一个 name
-			if node.name == name || node.name == "*" || strings.Contains(node.name, ":") {
-				children = node.children
-				t = node
-				routerName += "/" + node.name
-				node.routerName = routerName
-				// 最尾部的节点
-				if index == len(strs)-1 {
-					return node
+		node := t.match(name)
+		if node == nil {
+			// 没有匹配的节点时尝试 ** 匹配剩余的路径
+			for _, child := range t.children {
+				if child.name == "**" {
+					return child
 				}
-				break
 			}
+			return nil
 		}
-		if !isMatch {
-			for _, node := range children {
-				if node.name == "**" {
-					node.routerName += "/" + node.name
-					return node
-				}
-			}
+		t = node
+		// 最尾部的节点
+		if index == len(strs)-1 {
+			return node
 		}
 	}
 	return nil
 }
+
+// match 静态节点优先, 其次是参数和 * 节点
+func (t *treeNode) match(name string) *treeNode {
+	for _, node := range t.children {
+		if node.name == name && !isWildNode(node.name) {
+			return node
+		}
+	}
+	for _, node := range t.children {
+		if node.name == "*" || strings.Contains(node.name, ":") {
+			return node
+		}
+	}
+	return nil
+}
+
+// findCaseInsensitive 忽略大小写匹配路径, 返回按注册时大小写修正后的路径
+func (t *treeNode) findCaseInsensitive(path string) (string, bool) {
+	strs := strings.Split(path, "/")
+	if len(strs) < 2 || strs[0] != "" {
+		return "", false
+	}
+	return t.findSegments(strs[1:], "")
+}
+
+func (t *treeNode) findSegments(segments []string, prefix string) (string, bool) {
+	if len(segments) == 0 {
+		return prefix, t.isEnd
+	}
+	name := segments[0]
+	// 静态节点优先, 其次是参数和通配符
+	for _, node := range t.children {
+		if strings.EqualFold(node.name, name) && !isWildNode(node.name) {
+			if fixed, ok := node.findSegments(segments[1:], prefix+"/"+node.name); ok {
+				return fixed, true
+			}
+		}
+	}
+	for _, node := range t.children {
+		switch {
+		case node.name == "**":
+			return prefix + "/" + strings.Join(segments, "/"), true
+		case node.name == "*" || strings.Contains(node.name, ":"):
+			if fixed, ok := node.findSegments(segments[1:], prefix+"/"+name); ok {
+				return fixed, true
+			}
+		}
+	}
+	return "", false
+}
+
+func isWildNode(name string) bool {
+	return name == "*" || name == "**" || strings.Contains(name, ":")
+}
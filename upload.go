@@ -0,0 +1,282 @@
+package fesgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrUploadTooLarge      = errors.New("upload: request too large")
+	ErrFileTooLarge        = errors.New("upload: file too large")
+	ErrTooManyFiles        = errors.New("upload: too many files")
+	ErrFileTypeNotAllowed  = errors.New("upload: file type not allowed")
+	ErrInvalidFileName     = errors.New("upload: invalid file name")
+	ErrUploadStorageNotSet = errors.New("upload: storage is not set")
+)
+
+// sniffLen 嗅探文件类型读取的字节数
+const sniffLen = 3072
+
+// UploadStorage 上传文件的存储后端, 返回文件的访问位置
+type UploadStorage interface {
+	Save(name string, r io.Reader) (location string, err error)
+}
+
+type UploadConfig struct {
+	MaxFileSize  int64    // 单个文件的大小限制, <= 0 表示不限制
+	MaxTotalSize int64    // 所有文件和表单字段的大小限制, <= 0 表示不限制
+	MaxFiles     int      // 文件数量限制, <= 0 表示不限制
+	AllowedTypes []string // 允许的文件类型, 根据文件内容嗅探, 支持 image/* 的写法, 为空表示不限制
+	Storage      UploadStorage
+	// Rename 生成保存的文件名, 为空时使用清理后的原始文件名
+	Rename func(part *UploadPart) string
+}
+
+// UploadPart 上传的一个文件, 读取时按配置限制大小
+type UploadPart struct {
+	FieldName   string
+	FileName    string // 清理后的文件名, 不包含路径
+	ContentType string // 根据文件内容嗅探的类型
+	Header      textproto.MIMEHeader
+	io.Reader
+}
+
+type UploadedFile struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Size        int64
+	Location    string
+}
+
+// DiskStorage 保存到本地目录, 文件名不能跳出 Dir, 文件已存在时返回 os.ErrExist, 不会覆盖
+type DiskStorage struct {
+	Dir string
+}
+
+func (s *DiskStorage) Save(name string, r io.Reader) (string, error) {
+	dst, err := SafeJoin(s.Dir, name)
+	if err != nil {
+		return "", err
+	}
+	if err = createFile(dst, r); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// createFile 创建新文件写入 r 的内容, 文件已存在时返回 os.ErrExist
+func createFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(dst)
+		return err
+	}
+	return f.Close()
+}
+
+// SafeFileName 去掉客户端文件名中的路径和控制字符, 不合法时返回空字符串
+func SafeFileName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// SafeJoin 拼接目录和文件名, 结果不在目录内时返回错误
+func SafeJoin(dir, name string) (string, error) {
+	if name == "" || filepath.IsAbs(name) {
+		return "", ErrInvalidFileName
+	}
+	dst := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, dst)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidFileName
+	}
+	return dst, nil
+}
+
+// StreamUpload 逐个处理 multipart 中的文件, 不使用临时文件, handle 需要读取 part 中的内容
+// 普通表单字段在处理完成后可以通过 GetPostForm 获取
+func (c *Context) StreamUpload(config UploadConfig, handle func(part *UploadPart) error) error {
+	reader, err := c.R.MultipartReader()
+	if err != nil {
+		return err
+	}
+	form := url.Values{}
+	defer func() {
+		c.R.PostForm = form
+		c.formCache = form
+		c.formMap = ParseParamsMap(form)
+	}()
+	total := &limitCounter{limit: config.MaxTotalSize, err: ErrUploadTooLarge}
+	files := 0
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if p.FileName() == "" {
+			value, err := io.ReadAll(total.wrap(p))
+			if err != nil {
+				p.Close()
+				return err
+			}
+			form.Add(p.FormName(), string(value))
+			continue
+		}
+		files++
+		if config.MaxFiles > 0 && files > config.MaxFiles {
+			p.Close()
+			return ErrTooManyFiles
+		}
+		err = c.handlePart(config, p, total, handle)
+		if err == nil {
+			err = drainPart(p, total)
+		}
+		p.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// drainPart 读取 handle 没有读完的内容, 同样计入 MaxTotalSize, 避免 p.Close 不受限制地读取
+func drainPart(p *multipart.Part, total *limitCounter) error {
+	_, err := io.Copy(io.Discard, total.wrap(p))
+	return err
+}
+
+func (c *Context) handlePart(config UploadConfig, p *multipart.Part, total *limitCounter, handle func(part *UploadPart) error) error {
+	fileName := SafeFileName(p.FileName())
+	if fileName == "" {
+		return ErrInvalidFileName
+	}
+	fileLimit := &limitCounter{limit: config.MaxFileSize, err: ErrFileTooLarge}
+	r := fileLimit.wrap(total.wrap(p))
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	mtype := mimetype.Detect(head)
+	if !allowedType(mtype, config.AllowedTypes) {
+		return fmt.Errorf("%w: %s", ErrFileTypeNotAllowed, mtype.String())
+	}
+	return handle(&UploadPart{
+		FieldName:   p.FormName(),
+		FileName:    fileName,
+		ContentType: mtype.String(),
+		Header:      p.Header,
+		Reader:      io.MultiReader(bytes.NewReader(head), r),
+	})
+}
+
+// SaveUploads 将所有文件保存到 config.Storage
+func (c *Context) SaveUploads(config UploadConfig) ([]*UploadedFile, error) {
+	if config.Storage == nil {
+		return nil, ErrUploadStorageNotSet
+	}
+	uploaded := make([]*UploadedFile, 0)
+	err := c.StreamUpload(config, func(part *UploadPart) error {
+		name := part.FileName
+		if config.Rename != nil {
+			name = config.Rename(part)
+		}
+		counter := &limitCounter{}
+		location, err := config.Storage.Save(name, counter.wrap(part))
+		if err != nil {
+			return err
+		}
+		uploaded = append(uploaded, &UploadedFile{
+			FieldName:   part.FieldName,
+			FileName:    part.FileName,
+			ContentType: part.ContentType,
+			Size:        counter.read,
+			Location:    location,
+		})
+		return nil
+	})
+	return uploaded, err
+}
+
+func allowedType(mtype *mimetype.MIME, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, t := range allowed {
+		if strings.HasSuffix(t, "/*") {
+			for m := mtype; m != nil; m = m.Parent() {
+				if strings.HasPrefix(m.String(), strings.TrimSuffix(t, "*")) {
+					return true
+				}
+			}
+			continue
+		}
+		if mtype.Is(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitCounter 统计读取的字节数, 超过 limit 时返回 err, 多个 reader 可以共享同一个计数
+type limitCounter struct {
+	limit int64
+	read  int64
+	err   error
+}
+
+func (l *limitCounter) wrap(r io.Reader) io.Reader {
+	return &limitReader{r: r, counter: l}
+}
+
+type limitReader struct {
+	r       io.Reader
+	counter *limitCounter
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.counter.read += int64(n)
+	if l.counter.limit > 0 && l.counter.read > l.counter.limit {
+		// 超过限制的部分不返回给调用方
+		if over := l.counter.read - l.counter.limit; over < int64(n) {
+			n -= int(over)
+		} else {
+			n = 0
+		}
+		return n, l.counter.err
+	}
+	return n, err
+}
@@ -0,0 +1,178 @@
+package fesgo
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type uploadFile struct {
+	field, name string
+	content     []byte
+}
+
+func multipartRequest(fields map[string]string, files ...uploadFile) *http.Request {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	for _, f := range files {
+		part, _ := w.CreateFormFile(f.field, f.name)
+		part.Write(f.content)
+	}
+	w.Close()
+	r := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func TestSafeFileName(t *testing.T) {
+	testCase := []struct {
+		name string
+		want string
+	}{
+		{"a.png", "a.png"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\windows\win.ini`, "win.ini"},
+		{"/abs/path.txt", "path.txt"},
+		{"..", ""},
+		{"dir/", ""},
+		{"a\x00b.txt", "ab.txt"},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, SafeFileName(tc.name))
+		})
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	_, err := SafeJoin("/data", "../etc/passwd")
+	assert.ErrorIs(t, err, ErrInvalidFileName)
+	_, err = SafeJoin("/data", "/etc/passwd")
+	assert.ErrorIs(t, err, ErrInvalidFileName)
+	dst, err := SafeJoin("/data", "a/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/data", "a", "b.txt"), dst)
+}
+
+func TestSaveUploads(t *testing.T) {
+	testCase := []struct {
+		name   string
+		config UploadConfig
+		files  []uploadFile
+		err    error
+		status int
+	}{
+		{
+			name:   "ok",
+			config: UploadConfig{AllowedTypes: []string{"image/*"}},
+			files:  []uploadFile{{"avatar", "../../a.png", pngHeader}},
+		},
+		{
+			name:   "type not allowed",
+			config: UploadConfig{AllowedTypes: []string{"image/*"}},
+			files:  []uploadFile{{"avatar", "a.png", []byte("hello")}},
+			err:    ErrFileTypeNotAllowed,
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "file too large",
+			config: UploadConfig{MaxFileSize: 4},
+			files:  []uploadFile{{"avatar", "a.txt", []byte("hello")}},
+			err:    ErrFileTooLarge,
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "total too large",
+			config: UploadConfig{MaxFileSize: 8, MaxTotalSize: 12},
+			files:  []uploadFile{{"a", "a.txt", []byte("hello")}, {"b", "b.txt", []byte("world!!")}},
+			err:    ErrUploadTooLarge,
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "too many files",
+			config: UploadConfig{MaxFiles: 1},
+			files:  []uploadFile{{"a", "a.txt", []byte("a")}, {"b", "b.txt", []byte("b")}},
+			err:    ErrTooManyFiles,
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.config.Storage = &DiskStorage{Dir: dir}
+			ctx := &Context{R: multipartRequest(map[string]string{"title": "hi"}, tc.files...)}
+			files, err := ctx.SaveUploads(tc.config)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.Equal(t, tc.status, BodyErrorStatus(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "hi", ctx.GetPostForm("title"))
+			assert.Len(t, files, 1)
+			assert.Equal(t, "image/png", files[0].ContentType)
+			assert.Equal(t, int64(len(pngHeader)), files[0].Size)
+			assert.Equal(t, filepath.Join(dir, "a.png"), files[0].Location)
+			content, err := os.ReadFile(files[0].Location)
+			assert.NoError(t, err)
+			assert.Equal(t, pngHeader, content)
+		})
+	}
+}
+
+func TestStreamUploadUnreadPart(t *testing.T) {
+	// handle 没有读取的内容同样计入 MaxTotalSize
+	content := bytes.Repeat([]byte("a"), 2*sniffLen)
+	ctx := &Context{R: multipartRequest(nil, uploadFile{"a", "a.txt", content}, uploadFile{"b", "b.txt", content})}
+	err := ctx.StreamUpload(UploadConfig{MaxTotalSize: 3 * sniffLen}, func(part *UploadPart) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrUploadTooLarge)
+}
+
+func TestFormFile(t *testing.T) {
+	ctx := &Context{R: multipartRequest(nil, uploadFile{"file", "../x.txt", []byte("data")})}
+	_, err := ctx.FormFile("missing")
+	assert.Error(t, err)
+
+	header, err := ctx.FormFile("file")
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	dst, err := ctx.SaveUploadFileToDir(header, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "x.txt", filepath.Base(dst))
+
+	// 不覆盖已有的文件
+	_, err = ctx.SaveUploadFileToDir(header, dir)
+	assert.ErrorIs(t, err, os.ErrExist)
+}
+
+func TestDiskStorageExists(t *testing.T) {
+	storage := &DiskStorage{Dir: t.TempDir()}
+	_, err := storage.Save("a.txt", strings.NewReader("first"))
+	assert.NoError(t, err)
+	_, err = storage.Save("a.txt", strings.NewReader("second"))
+	assert.ErrorIs(t, err, os.ErrExist)
+	content, err := os.ReadFile(filepath.Join(storage.Dir, "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+}
+
+func TestLimitReader(t *testing.T) {
+	counter := &limitCounter{limit: 4, err: ErrFileTooLarge}
+	data, err := io.ReadAll(counter.wrap(strings.NewReader("hello")))
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	assert.Equal(t, "hell", string(data))
+}
@@ -2,6 +2,7 @@ package fesgo
 
 import (
 	"net/url"
+	"path"
 	"strings"
 	"unicode"
 )
@@ -42,3 +43,18 @@ func ParseParamsMap(values url.Values) map[string]map[string]string {
 
 	return m
 }
+
+// CleanPath 清理路径中的 .、.. 和重复的 /, 保留末尾的 /
+func CleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
@@ -0,0 +1,45 @@
+package fesgo
+
+import (
+	"errors"
+	"github.com/dalefeng/fesgo/websocket"
+	"net/http"
+)
+
+type WebSocketHandler func(ctx *Context, conn *websocket.Conn)
+
+// WebSocket 注册 websocket 路由, 升级在路由和组中间件之后执行, 认证中间件可以在升级前拒绝请求
+func (r *routerGroup) WebSocket(name string, handler WebSocketHandler, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodGet, func(ctx *Context) {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			if ctx.Logger != nil {
+				ctx.Logger.Error(err)
+			}
+			return
+		}
+		defer conn.Close()
+		handler(ctx, conn)
+	}, middlewareFunc...)
+}
+
+// Upgrade 将当前请求升级为 websocket 连接, 失败时已经写入了错误响应
+func (c *Context) Upgrade() (*websocket.Conn, error) {
+	var upgrader *websocket.Upgrader
+	if c.engine != nil {
+		upgrader = c.engine.WebSocketUpgrader
+	}
+	if upgrader == nil {
+		upgrader = &websocket.Upgrader{}
+	}
+	conn, err := upgrader.Upgrade(c.W, c.R, nil)
+	if err != nil {
+		var herr websocket.HandshakeError
+		if errors.As(err, &herr) {
+			c.StatusCode = herr.Status
+		}
+		return nil, err
+	}
+	c.StatusCode = http.StatusSwitchingProtocols
+	return conn, nil
+}
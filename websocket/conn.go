@@ -0,0 +1,421 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型, 对应 RFC 6455 的 opcode
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// 关闭状态码
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseInvalidPayloadData = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseInternalServerErr  = 1011
+)
+
+const (
+	finBit  = 1 << 7
+	rsvBits = 7 << 4
+	maskBit = 1 << 7
+
+	maxControlPayload = 125
+	defaultReadLimit  = 1 << 20  // 1M
+	maxPreallocLength = 64 << 10 // 帧长度超过时按实际收到的数据分配内存
+)
+
+var (
+	ErrReadLimit  = errors.New("websocket: read limit exceeded")
+	ErrCloseSent  = errors.New("websocket: close sent")
+	errBadOpcode  = errors.New("websocket: bad opcode")
+	errBadControl = errors.New("websocket: invalid control frame")
+)
+
+// CloseError 收到对端的关闭帧
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+	readLimit   int64
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	readErr     error
+	pingHandler func(appData string) error
+	pongHandler func(appData string) error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool, readLimit int64) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit
+	}
+	c := &Conn{
+		conn:      conn,
+		br:        br,
+		isServer:  isServer,
+		readLimit: readLimit,
+	}
+	c.pingHandler = func(appData string) error {
+		err := c.WriteControl(PongMessage, []byte(appData))
+		if errors.Is(err, ErrCloseSent) {
+			return nil
+		}
+		return err
+	}
+	c.pongHandler = func(string) error { return nil }
+	return c
+}
+
+// Subprotocol 握手时协商的子协议
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler 收到 ping 时调用, 默认回复 pong
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pingHandler = h
+}
+
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pongHandler = h
+}
+
+// ReadMessage 读取一条完整的消息, 分片的消息会被合并, 控制帧在内部处理
+// 收到关闭帧时回复关闭帧并返回 *CloseError
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	messageType, p, err = c.readMessage()
+	if err != nil {
+		c.readErr = err
+	}
+	return
+}
+
+func (c *Conn) readMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame(int64(len(message)))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			if err = c.pingHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if err = c.pongHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected data frame")
+			}
+			messageType = opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		}
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(CloseInvalidPayloadData, "invalid utf8 payload")
+		}
+		return messageType, message, nil
+	}
+}
+
+// readFrame 读取一帧, read 为当前消息已读取的长度, 用于检查消息大小限制
+func (c *Conn) readFrame(read int64) (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&finBit != 0
+	opcode = int(head[0] & 0xf)
+	masked := head[1]&maskBit != 0
+	length := int64(head[1] & 0x7f)
+
+	// 没有协商扩展, RSV 位必须为 0
+	if head[0]&rsvBits != 0 {
+		err = c.fail(CloseProtocolError, "unexpected reserved bits")
+		return
+	}
+	switch opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin || length > maxControlPayload {
+			err = c.fail(CloseProtocolError, errBadControl.Error())
+			return
+		}
+	default:
+		err = c.fail(CloseProtocolError, errBadOpcode.Error())
+		return
+	}
+	// 客户端发送的帧必须掩码, 服务端发送的帧不能掩码
+	if masked != c.isServer {
+		err = c.fail(CloseProtocolError, "incorrect mask flag")
+		return
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			err = c.fail(CloseProtocolError, "invalid payload length")
+			return
+		}
+	}
+	if opcode < CloseMessage && c.readLimit > 0 && length > c.readLimit-read {
+		c.fail(CloseMessageTooBig, "message too big")
+		err = ErrReadLimit
+		return
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, maskKey[:]); err != nil {
+			return
+		}
+	}
+	if payload, err = readPayload(c.br, length); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(maskKey, payload)
+	}
+	return
+}
+
+// readPayload 读取帧的数据, 长度来自对端, 较大时不按长度一次分配内存
+func readPayload(r io.Reader, length int64) ([]byte, error) {
+	if length <= maxPreallocLength {
+		payload := make([]byte, length)
+		_, err := io.ReadFull(r, payload)
+		return payload, err
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, length))
+	if err == nil && n < length {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func (c *Conn) handleClose(payload []byte) error {
+	code, text := CloseNoStatusReceived, ""
+	if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) || !utf8.ValidString(text) {
+			return c.fail(CloseProtocolError, "invalid close payload")
+		}
+	}
+	// 回复关闭帧完成关闭握手
+	replyCode := code
+	if code == CloseNoStatusReceived {
+		replyCode = CloseNormalClosure
+	}
+	c.WriteControl(CloseMessage, FormatCloseMessage(replyCode, ""))
+	return &CloseError{Code: code, Text: text}
+}
+
+// fail 协议错误时发送关闭帧
+func (c *Conn) fail(code int, text string) error {
+	c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+	return &CloseError{Code: code, Text: text}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		// 1004、1005、1006 不能出现在关闭帧中
+		return code != 1004 && code != 1005 && code != 1006
+	default:
+		return false
+	}
+}
+
+// FormatCloseMessage 生成关闭帧的内容
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+// WriteMessage 以单帧写入一条消息
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return c.WriteControl(messageType, data)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+func (c *Conn) WriteText(text string) error {
+	return c.writeFrame(TextMessage, []byte(text))
+}
+
+// WriteControl 写入控制帧, 内容不能超过 125 字节
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	switch messageType {
+	case CloseMessage, PingMessage, PongMessage:
+	default:
+		return errBadOpcode
+	}
+	if len(data) > maxControlPayload {
+		return errBadControl
+	}
+	return c.writeFrame(messageType, data)
+}
+
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteControl(PingMessage, data)
+}
+
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	length := len(data)
+	frame := make([]byte, 0, 14+length)
+	frame = append(frame, finBit|byte(opcode))
+	var maskFlag byte
+	if !c.isServer {
+		maskFlag = maskBit
+	}
+	switch {
+	case length <= 125:
+		frame = append(frame, maskFlag|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, maskFlag|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, maskFlag|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	if c.isServer {
+		frame = append(frame, data...)
+	} else {
+		var maskKey [4]byte
+		if _, err := rand.Read(maskKey[:]); err != nil {
+			return err
+		}
+		frame = append(frame, maskKey[:]...)
+		start := len(frame)
+		frame = append(frame, data...)
+		maskBytes(maskKey, frame[start:])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// CloseWithCode 发送关闭帧, 之后可以继续 ReadMessage 等待对端的关闭帧
+func (c *Conn) CloseWithCode(code int, text string) error {
+	return c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+}
+
+// Close 发送正常关闭帧后关闭底层连接
+func (c *Conn) Close() error {
+	c.CloseWithCode(CloseNormalClosure, "")
+	return c.conn.Close()
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
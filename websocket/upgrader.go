@@ -0,0 +1,172 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HandshakeError 握手失败, Status 为已经写入响应的状态码
+type HandshakeError struct {
+	Status  int
+	message string
+}
+
+func (e HandshakeError) Error() string {
+	return "websocket: " + e.message
+}
+
+type Upgrader struct {
+	ReadLimit        int64         // 单条消息的最大长度, <= 0 时使用默认值 1M
+	HandshakeTimeout time.Duration // 写入握手响应的超时时间
+	Subprotocols     []string      // 服务端支持的子协议, 按优先级排列
+	// CheckOrigin 校验 Origin, 为空时只允许同源请求
+	CheckOrigin func(r *http.Request) bool
+}
+
+func (u *Upgrader) error(w http.ResponseWriter, status int, message string) error {
+	err := HandshakeError{Status: status, message: message}
+	w.Header().Set("Sec-WebSocket-Version", "13")
+	http.Error(w, http.StatusText(status), status)
+	return err
+}
+
+// Upgrade 将 http 连接升级为 websocket 连接, 失败时已经写入了错误响应
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, u.error(w, http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !tokenListContains(r.Header, "Connection", "upgrade") {
+		return nil, u.error(w, http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !tokenListContains(r.Header, "Upgrade", "websocket") {
+		return nil, u.error(w, http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, u.error(w, http.StatusUpgradeRequired, "unsupported version")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, u.error(w, http.StatusForbidden, "request origin not allowed")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, u.error(w, http.StatusBadRequest, "invalid 'Sec-WebSocket-Key' header")
+	}
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, u.error(w, http.StatusInternalServerError, "response does not implement http.Hijacker")
+	}
+	subprotocol := u.selectSubprotocol(r)
+
+	netConn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if brw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errors.New("websocket: client sent data before handshake is complete")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	sb.WriteString(acceptKey(key))
+	sb.WriteString("\r\n")
+	if subprotocol != "" {
+		sb.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	for k, values := range responseHeader {
+		if k == "Sec-Websocket-Protocol" {
+			continue
+		}
+		for _, v := range values {
+			sb.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	sb.WriteString("\r\n")
+
+	if u.HandshakeTimeout > 0 {
+		netConn.SetWriteDeadline(time.Now().Add(u.HandshakeTimeout))
+	}
+	if _, err = netConn.Write([]byte(sb.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if u.HandshakeTimeout > 0 {
+		netConn.SetWriteDeadline(time.Time{})
+	}
+
+	conn := newConn(netConn, brw.Reader, true, u.ReadLimit)
+	conn.subprotocol = subprotocol
+	return conn, nil
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	clientProtocols := Subprotocols(r)
+	for _, serverProtocol := range u.Subprotocols {
+		for _, clientProtocol := range clientProtocols {
+			if clientProtocol == serverProtocol {
+				return clientProtocol
+			}
+		}
+	}
+	return ""
+}
+
+// Subprotocols 客户端请求的子协议
+func Subprotocols(r *http.Request) []string {
+	protocols := make([]string, 0)
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
+
+// SameOrigin 没有 Origin 或者 Origin 的 host 与请求的 Host 相同
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// IsWebSocketUpgrade 是否是 websocket 升级请求
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return tokenListContains(r.Header, "Connection", "upgrade") &&
+		tokenListContains(r.Header, "Upgrade", "websocket")
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func tokenListContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
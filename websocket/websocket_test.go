@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func dial(t *testing.T, server *httptest.Server, header http.Header) (*Conn, *http.Response) {
+	netConn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	assert.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	assert.NoError(t, req.Write(netConn))
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	assert.NoError(t, err)
+	return newConn(netConn, br, false, 0), resp
+}
+
+func echoServer(u *Upgrader) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(messageType, p); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestUpgrade(t *testing.T) {
+	server := echoServer(&Upgrader{Subprotocols: []string{"chat"}})
+	defer server.Close()
+
+	conn, resp := dial(t, server, http.Header{"Sec-Websocket-Protocol": {"other, chat"}})
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	assert.Equal(t, "chat", resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	assert.NoError(t, conn.WriteText("hello"))
+	messageType, p, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TextMessage, messageType)
+	assert.Equal(t, "hello", string(p))
+
+	big := make([]byte, 70000)
+	assert.NoError(t, conn.WriteMessage(BinaryMessage, big))
+	messageType, p, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, BinaryMessage, messageType)
+	assert.Equal(t, big, p)
+
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(appData string) error {
+		pong <- appData
+		return nil
+	})
+	assert.NoError(t, conn.Ping([]byte("ping")))
+	assert.NoError(t, conn.WriteText("after ping"))
+	_, p, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "after ping", string(p))
+	assert.Equal(t, "ping", <-pong)
+
+	// 关闭握手: 服务端回复关闭帧
+	assert.NoError(t, conn.CloseWithCode(CloseNormalClosure, "bye"))
+	_, _, err = conn.ReadMessage()
+	var closeErr *CloseError
+	assert.True(t, errors.As(err, &closeErr))
+	assert.Equal(t, CloseNormalClosure, closeErr.Code)
+	conn.conn.Close()
+}
+
+func TestReadLimit(t *testing.T) {
+	server := echoServer(&Upgrader{ReadLimit: 16})
+	defer server.Close()
+
+	conn, _ := dial(t, server, nil)
+	defer conn.conn.Close()
+	assert.NoError(t, conn.WriteText(strings.Repeat("a", 17)))
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	assert.True(t, errors.As(err, &closeErr))
+	assert.Equal(t, CloseMessageTooBig, closeErr.Code)
+}
+
+func TestHandshakeError(t *testing.T) {
+	server := echoServer(&Upgrader{})
+	defer server.Close()
+
+	_, resp := dial(t, server, http.Header{"Origin": {"http://evil.example.com"}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	_, resp = dial(t, server, http.Header{"Sec-Websocket-Version": {"8"}})
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+	assert.Equal(t, "13", resp.Header.Get("Sec-WebSocket-Version"))
+
+	_, resp = dial(t, server, http.Header{"Sec-Websocket-Key": {"short"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestReadFrameLength(t *testing.T) {
+	testCase := []struct {
+		name  string
+		limit int64
+		frame []byte
+		err   error
+	}{
+		{
+			// 已读取的长度加上帧长度会溢出
+			name:  "overflow",
+			limit: 16,
+			frame: []byte{0x01, 0x81, 0, 0, 0, 0, 'a', 0x80, 0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			err:   ErrReadLimit,
+		},
+		{
+			// 没有限制时不按伪造的长度分配内存
+			name:  "no limit",
+			limit: 0,
+			frame: []byte{0x82, 0xff, 0, 0, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 'a'},
+			err:   io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			go func() {
+				client.Write(tc.frame)
+				client.Close()
+			}()
+			conn := newConn(server, nil, true, 0)
+			conn.SetReadLimit(tc.limit)
+			_, _, err := conn.ReadMessage()
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package fesgo
+
+import (
+	"github.com/dalefeng/fesgo/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebSocketMiddleware(t *testing.T) {
+	engine := NewEngine()
+	group := engine.Group("api")
+	auth := func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if ctx.GetQuery("token") != "secret" {
+				ctx.SetStatusCode(http.StatusUnauthorized)
+				return
+			}
+			next(ctx)
+		}
+	}
+	group.WebSocket("/ws", func(ctx *Context, conn *websocket.Conn) {
+		conn.WriteText("hello")
+	}, auth)
+
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	testCase := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "unauthorized", query: "", want: http.StatusUnauthorized},
+		{name: "upgrade", query: "?token=secret", want: http.StatusSwitchingProtocols},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(tt *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/ws"+tc.query, nil)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(tt, err)
+			defer resp.Body.Close()
+			assert.Equal(tt, tc.want, resp.StatusCode)
+		})
+	}
+}